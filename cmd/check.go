@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/worker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	rootFlag(checkCmd)
+	verboseFlag(checkCmd)
+	probeWorkerNumFlag(checkCmd)
+	configFlag(checkCmd)
+	readIndexFlag(checkCmd)
+	walkFlag(checkCmd)
+	cueFlag(checkCmd)
+	chapterFlag(checkCmd)
+	checkCmd.Flags().String("ffmpeg", "ffmpeg", "Decoder command")
+	checkCmd.Flags().Duration("duration-tolerance", time.Second, "Acceptable difference between the duration from the metadata and the decoded duration")
+	checkCmd.Flags().Bool("all", false, "Output the files without problems too")
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check [QUERY...]",
+	Short: `Decode the matching media files and report problems`,
+	Long: `Decode the matching media files and report problems.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+The matching files are decoded by ffmpeg (-f null) and the following problems are reported in jsonl format:
+
+- errors: Decode errors
+- truncated: true if the streams seem to be truncated
+- duration_mismatch: true if the decoded duration differs from the duration of the metadata
+
+By default, only the files with problems are output.
+Each file is checked once even if it matches multiple times, e.g. the tracks of the CUE sheet by '--cue' or the chapters by '--chapter-records'.
+The members of the archives by '--archive' are skipped because ffmpeg cannot read them from the path.
+
+Requirements:
+- ffmpeg 7.1 https://ffmpeg.org/ffmpeg.html
+
+Examples:
+# check flac files in ~/Music
+fflist check -r ~/Music 'ext=\.flac$'
+# check the files in the index
+fflist check --readIndex index 'ext=\.flac$'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		selector, root, err := parseSelector(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		var (
+			ffmpeg, _    = cmd.Flags().GetString("ffmpeg")
+			tolerance, _ = cmd.Flags().GetDuration("duration-tolerance")
+			all, _       = cmd.Flags().GetBool("all")
+			checkWorker  = worker.NewCheck(meta.NewChecker(ffmpeg, tolerance), getProbeWorkerNum(cmd))
+		)
+
+		c := run.NewCheck(
+			source,
+			selector,
+			checkWorker,
+			os.Stdout,
+			all,
+			getVerbose(cmd),
+		)
+		return c.Run(cmd.Context())
+	},
+}
@@ -5,13 +5,13 @@ import (
 	"os"
 
 	"github.com/berquerant/fflist/logx"
-	"github.com/berquerant/fflist/meta"
 	"github.com/berquerant/fflist/worker"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(debugCmd)
+	walkFlag(debugCmd)
 }
 
 var debugCmd = &cobra.Command{
@@ -29,15 +29,16 @@ fflint debug -`,
 			roots = args
 		}
 
-		newWalker, err := newWalkerFactory(roots)
+		newWalker, err := newWalkerFactory(cmd, roots)
 		if err != nil {
 			return err
 		}
 
-		var (
-			walker = newWalker()
-			prober = meta.NewProber(getProbe(cmd))
-		)
+		prober, err := newProber(cmd)
+		if err != nil {
+			return err
+		}
+		walker := newWalker()
 
 		for _, root := range roots {
 			for x := range walker.Walk(root) {
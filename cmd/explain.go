@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	probeWorkerNumFlag(explainCmd)
+	configFlag(explainCmd)
+	readIndexFlag(explainCmd)
+	cueFlag(explainCmd)
+	chapterFlag(explainCmd)
+	walkFlag(explainCmd)
+	probeFailureRecordFlag(explainCmd)
+	groupFlag(explainCmd)
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain QUERY... [PATH...]",
+	Short: `Explain how the QUERY matches the files`,
+	Long: `Explain how the QUERY matches the files.
+
+The QUERY is the same as the 'query' command.
+The arguments up to the first one that is neither 'key=value', '@name' nor "or" are QUERY, and the rest are PATH.
+Use '--' to separate them explicitly, e.g. when PATH contains '='.
+When the '--config' option is specified, QUERY overrides config.query, and config.query is used if QUERY is empty.
+
+The output is in jsonl format.
+The first line is the selector tree parsed from the QUERY.
+Each of the following lines is the trace of the selector for the file under PATH, with the following fields:
+
+- path: The path of the file
+- result: true if the file matches the QUERY
+- trace: The selector tree with the evaluation results
+
+The nodes of the selector tree have the following fields:
+
+- type: and, or, regexp, script, coprocess, expr
+- key: The key of the QUERY
+- query: The value of the QUERY
+- value: The actual value of the key
+- found: false if the file does not have the key
+- result: The result of the node
+- error: The error of the node, e.g. the exit status of the script
+- children: The nested nodes
+
+Unlike the 'query' command, all the nodes are evaluated even if the result is determined.
+
+Using the '--readIndex' option, the metadata are read from the index instead of probing PATH,
+and the files not in PATH are ignored unless PATH is empty.
+
+Examples:
+# show the selector tree
+fflist explain name=NAME1 OR name=NAME2 artist=ARTIST
+# explain why the file does not match
+fflist explain 'artist=ARTIST' 'genre=GENRE' ~/Music/track.mp3
+# explain the config query for the files in the index
+fflist explain -c config.yml --readIndex index -- /music/a.mp3
+# explain the QUERY with the named queries of the config
+fflist explain -c config.yml --readIndex index @name genre=GENRE /music/a.mp3`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		queryArgs, paths := splitExplainArgs(cmd, args)
+		selector, _, err := parseSelector(cmd, queryArgs)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+
+		var source run.Source
+		switch {
+		case len(getReadIndex(cmd)) > 0:
+			s, closer, err := newSource(cmd, nil)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			source = s
+		case len(paths) > 0:
+			s, closer, err := newSource(cmd, paths)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			source = s
+			// all the walked files are explained
+			paths = nil
+		}
+
+		return run.NewExplain(source, selector, os.Stdout, paths).Run(cmd.Context())
+	},
+}
+
+// splitExplainArgs splits the arguments into QUERY and PATH.
+func splitExplainArgs(cmd *cobra.Command, args []string) ([]string, []string) {
+	if i := cmd.ArgsLenAtDash(); i >= 0 {
+		return args[:i], args[i:]
+	}
+	for i, a := range args {
+		if isQueryArg(a) {
+			continue
+		}
+		return args[:i], args[i:]
+	}
+	return args, nil
+}
+
+func isQueryArg(arg string) bool {
+	switch arg {
+	case "or", "OR":
+		return true
+	default:
+		if _, ok := run.NamedQueryName(arg); ok {
+			return true
+		}
+		_, err := query.Parse(arg)
+		return err == nil
+	}
+}
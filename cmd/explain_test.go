@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainConfigAndQuery(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		config = filepath.Join(dir, "config.yml")
+		index  = filepath.Join(dir, "index")
+		a      = filepath.Join(dir, "a.mp3")
+		b      = filepath.Join(dir, "b.mp3")
+	)
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(`root:
+  - %q
+query:
+  - ["artist=A"]
+`, dir)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(index, []byte(fmt.Sprintf(`{"path":%q,"artist":"A"}
+{"path":%q,"artist":"B"}
+`, a, b)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	outC := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		outC <- b
+	}()
+
+	rootCmd.SetArgs([]string{"explain", "-c", config, "--readIndex", index, "artist=B", a, b})
+	err = rootCmd.Execute()
+	w.Close()
+	out := <-outC
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	got := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Scan() // the selector tree
+	for scanner.Scan() {
+		var x struct {
+			Path   string `json:"path"`
+			Result bool   `json:"result"`
+		}
+		if !assert.Nil(t, json.Unmarshal(scanner.Bytes(), &x)) {
+			return
+		}
+		got[filepath.Base(x.Path)] = x.Result
+	}
+	assert.Equal(t, map[string]bool{
+		"a.mp3": false,
+		"b.mp3": true,
+	}, got)
+}
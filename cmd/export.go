@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/berquerant/fflist/export"
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/worker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	rootFlag(exportCmd)
+	verboseFlag(exportCmd)
+	probeWorkerNumFlag(exportCmd)
+	configFlag(exportCmd)
+	readIndexFlag(exportCmd)
+	walkFlag(exportCmd)
+	exportCmd.Flags().String("preset", "", "Name of the preset")
+	exportCmd.Flags().String("presets", "", "Presets file. Default is config.presets")
+	exportCmd.Flags().String("dest", "", "Destination directory")
+	exportCmd.Flags().String("template", "", "Template of the destination path relative to '--dest'. Default is the path relative to '--base'")
+	exportCmd.Flags().StringSlice("base", nil, "Base directories of the relative paths. Default is '--root' or config.root")
+	exportCmd.Flags().Bool("dry-run", false, "Output the destinations without transcoding the files")
+	exportCmd.Flags().String("ffmpeg", "ffmpeg", "Transcoder command")
+	_ = exportCmd.MarkFlagRequired("preset")
+	_ = exportCmd.MarkFlagRequired("dest")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [QUERY...]",
+	Short: `Transcode the matching media files into the destination directory`,
+	Long: `Transcode the matching media files into the destination directory.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+No files are exported without QUERY, use e.g. "path=." to select all the files.
+
+The files are transcoded by ffmpeg with the preset, and the tags are copied (-map_metadata 0).
+The presets are read from the file specified by the '--presets' option or 'presets' of the config.
+The presets have the following format:
+
+mp3-320:          # name of the preset
+  ext: .mp3       # extension of the output
+  args:           # output options of ffmpeg
+  - -c:a
+  - libmp3lame
+  - -b:a
+  - 320k
+  - -id3v2_version
+  - "3"
+
+The destination path mirrors the path relative to the '--base' directories,
+or is rendered by the '--template', the same as the 'organize' command.
+The extension of the destination is replaced with the extension of the preset.
+The files are skipped if the destinations are newer than them.
+The transcoded file is written into a temporary file in the destination directory and renamed after ffmpeg succeeds.
+The transcoded file has the permissions of the source file.
+
+The results are output in jsonl format as the files finish with the following fields:
+
+- source: The path of the file
+- dest: The destination path
+- skip: The reason why the file is skipped
+- error: The error of the transcoding
+- dry_run: true if the file would be transcoded without '--dry-run'
+- elapsed: The duration of the transcoding in seconds
+
+The summary is output to stderr.
+
+Requirements:
+- ffmpeg 7.1 https://ffmpeg.org/ffmpeg.html
+
+Examples:
+# export the flac files in ~/Music to the player mirroring the tree
+fflist export -r ~/Music --presets presets.yml --preset mp3-320 --dest /Volumes/PLAYER/Music 'ext=\.flac$'
+# export the files of the config by config.presets into the directories of the artists
+fflist export -c config.yml --preset mp3-320 --dest /Volumes/PLAYER/Music \
+  --template '{{.artist}}/{{.album}}/{{.name}}'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			presetName, _ = cmd.Flags().GetString("preset")
+			dest, _       = cmd.Flags().GetString("dest")
+			text, _       = cmd.Flags().GetString("template")
+			bases, _      = cmd.Flags().GetStringSlice("base")
+			dryRun, _     = cmd.Flags().GetBool("dry-run")
+			ffmpeg, _     = cmd.Flags().GetString("ffmpeg")
+		)
+		presets, err := getPresets(cmd)
+		if err != nil {
+			return err
+		}
+		preset, ok := presets[presetName]
+		if !ok {
+			return fmt.Errorf("%w: unknown preset %s", errArgument, presetName)
+		}
+		var tmpl *layout.Template
+		if text != "" {
+			if tmpl, err = layout.New(text); err != nil {
+				return err
+			}
+		}
+
+		selector, root, err := parseSelector(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+		if len(bases) == 0 {
+			bases = root
+		}
+		exporter, err := export.New(
+			meta.NewTranscoder(ffmpeg),
+			preset,
+			run.ExpandEnvAll(dest)[0],
+			tmpl,
+			run.ExpandEnvAll(bases...),
+			dryRun,
+		)
+		if err != nil {
+			return err
+		}
+
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		return run.NewExport(
+			source,
+			selector,
+			worker.NewExport(exporter, getProbeWorkerNum(cmd)),
+			os.Stdout,
+			os.Stderr,
+			getVerbose(cmd),
+		).Run(cmd.Context())
+	},
+}
+
+func getPresets(cmd *cobra.Command) (map[string]meta.Preset, error) {
+	if file, _ := cmd.Flags().GetString("presets"); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return run.ParsePresets(f)
+	}
+
+	config, err := getConfig(cmd)
+	switch {
+	case err == nil:
+		if len(config.Presets) == 0 {
+			return nil, fmt.Errorf("%w: no presets in config", errArgument)
+		}
+		return config.Presets, nil
+	case errors.Is(err, errNoConfig):
+		return nil, fmt.Errorf("%w: no presets, please specify '--presets' or '--config'", errArgument)
+	default:
+		return nil, err
+	}
+}
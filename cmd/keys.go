@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	rootFlag(keysCmd)
+	verboseFlag(keysCmd)
+	probeWorkerNumFlag(keysCmd)
+	configFlag(keysCmd)
+	readIndexFlag(keysCmd)
+	cueFlag(keysCmd)
+	chapterFlag(keysCmd)
+	walkFlag(keysCmd)
+	probeFailureRecordFlag(keysCmd)
+	groupFlag(keysCmd)
+	keysCmd.Flags().Int("top", 5, "Number of the most frequent values per key")
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys [QUERY...]",
+	Short: `List the keys of the matching media files`,
+	Long: `List the keys of the matching media files.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+Without QUERY, all the files are aggregated.
+The keys are output in jsonl format with the following fields:
+
+- key: The name of the key
+- count: The number of the files having the key
+- type: The type inferred from all the non-empty values: int, float, duration, date or string
+- distinct: The number of the distinct values, up to 10000
+- top: The most frequent values and their counts
+
+Examples:
+# list the keys in ~/Music
+fflist keys -r ~/Music
+# list the keys of the flac files in the index, with 10 most frequent values
+fflist keys --readIndex index --top 10 'ext=\.flac$'
+# list the keys having many distinct values
+fflist keys --readIndex index | jq -c 'select(.distinct > 100) | {key, type}'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		selector, root, err := parseSelectorOrAll(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		top, _ := cmd.Flags().GetInt("top")
+		if top < 0 {
+			return fmt.Errorf("%w: negative top %d", errArgument, top)
+		}
+		return run.NewKeys(
+			source,
+			selector,
+			os.Stdout,
+			top,
+			getVerbose(cmd),
+		).Run(cmd.Context())
+	},
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/berquerant/fflist/lint"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	rootFlag(lintCmd)
+	verboseFlag(lintCmd)
+	probeWorkerNumFlag(lintCmd)
+	configFlag(lintCmd)
+	readIndexFlag(lintCmd)
+	cueFlag(lintCmd)
+	chapterFlag(lintCmd)
+	walkFlag(lintCmd)
+	probeFailureRecordFlag(lintCmd)
+	groupFlag(lintCmd)
+	lintCmd.Flags().String("rules", "", "Lint rules file. Default is config.lint")
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [QUERY...]",
+	Short: `Check the metadata of the matching media files against the rules`,
+	Long: `Check the metadata of the matching media files against the rules.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+Without QUERY, all the files are checked.
+
+The rules are read from the file specified by the '--rules' option or 'lint' of the config.
+The rules have the following format:
+
+required: # the keys should exist
+  - ext: [mp3, flac] # optional, the extensions of the files to which the rule applies
+    keys: [artist, album, title, track]
+format: # the value of the key should have the format if the key exists
+  - key: track
+    pattern: '^\d+(/\d+)?$' # regular expression
+  - key: date
+    type: date # int, float, duration or date
+consistent: # the values of the keys should be the same within a directory
+  - keys: [album, album_artist]
+disallowed: # the values of the keys should not contain the characters
+  - keys: [title, artist] # optional, all keys by default
+    chars: '<>:"/\|?*'
+
+The violations are output in jsonl format with the following fields:
+
+- path: The path of the file
+- rule: required, format, consistent or disallowed
+- key: The key violating the rule
+- value: The value of the key
+- expected: The expected format, the most common value within the directory, or the disallowed characters
+
+Examples:
+# lint ~/Music
+fflist lint -r ~/Music --rules rules.yml
+# lint the flac files in the index
+fflist lint --readIndex index --rules rules.yml 'ext=\.flac$'
+# lint the files of the config by config.lint
+fflist lint -c config.yml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rules, err := getLintConfig(cmd)
+		if err != nil {
+			return err
+		}
+		linter, err := lint.New(*rules)
+		if err != nil {
+			return err
+		}
+
+		selector, root, err := parseSelectorOrAll(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		return run.NewLint(
+			source,
+			selector,
+			linter,
+			os.Stdout,
+			getVerbose(cmd),
+		).Run(cmd.Context())
+	},
+}
+
+func getLintConfig(cmd *cobra.Command) (*lint.Config, error) {
+	if file, _ := cmd.Flags().GetString("rules"); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return run.ParseLintConfig(f)
+	}
+
+	config, err := getConfig(cmd)
+	switch {
+	case err == nil:
+		if config.Lint.IsEmpty() {
+			return nil, fmt.Errorf("%w: no lint rules in config", errArgument)
+		}
+		return &config.Lint, nil
+	case errors.Is(err, errNoConfig):
+		return nil, fmt.Errorf("%w: no lint rules, please specify '--rules' or '--config'", errArgument)
+	default:
+		return nil, err
+	}
+}
@@ -5,18 +5,21 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"syscall"
 )
 
 func main() {
-	ctx, stop := signal.NotifyContext(
-		context.Background(),
-		os.Interrupt,
-		syscall.SIGPIPE,
-	)
+	ctx, stop := notifyContext(context.Background())
 	defer stop()
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		slog.Error("Err", slog.Any("err", err))
 	}
 }
+
+// notifyContext returns a context canceled by the interrupt.
+//
+// SIGPIPE is not notified because writing to the stdin of the exited scripts (sh, shco) raises it.
+// Writing to the closed stdout still terminates the process by default.
+func notifyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(ctx, os.Interrupt)
+}
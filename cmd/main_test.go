@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyContextIgnoresSIGPIPE(t *testing.T) {
+	ctx, stop := notifyContext(context.Background())
+	defer stop()
+
+	// the script closes stdin, so that writing to the script raises SIGPIPE
+	s := query.NewCoprocessSelector(query.NewQuery("shco", `exec 0<&- ; sleep 1`), 5*time.Second)
+	defer s.Close()
+	data := info.New(meta.NewData(map[string]string{
+		"value": strings.Repeat("x", 1024*1024),
+	}))
+	assert.False(t, s.Select(ctx, data))
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("canceled by SIGPIPE")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/organize"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(organizeCmd)
+	organizeCmd.AddCommand(organizeUndoCmd)
+	rootFlag(organizeCmd)
+	verboseFlag(organizeCmd)
+	probeWorkerNumFlag(organizeCmd)
+	configFlag(organizeCmd)
+	readIndexFlag(organizeCmd)
+	walkFlag(organizeCmd)
+	groupFlag(organizeCmd)
+	organizeCmd.Flags().String("template", "", "Template of the destination path relative to '--dest'")
+	organizeCmd.Flags().String("dest", "", "Destination directory")
+	organizeCmd.Flags().String("mode", string(organize.Move), "How to place the files: move, copy, hardlink or symlink")
+	organizeCmd.Flags().String("collision", string(organize.Skip), `What to do when the destination exists.
+skip: skip the file
+overwrite: replace the existing file
+rename: append a number to the name, e.g. name (1).mp3
+error: report an error`)
+	organizeCmd.Flags().Bool("dry-run", false, "Output the plan without placing the files")
+	organizeCmd.Flags().String("undo-log", "", "Append the executed operations to the file to undo them by 'fflist organize undo'")
+	_ = organizeCmd.MarkFlagRequired("template")
+	_ = organizeCmd.MarkFlagRequired("dest")
+}
+
+var organizeCmd = &cobra.Command{
+	Use:   "organize [QUERY...]",
+	Short: `Place the matching media files by the template of their metadata`,
+	Long: `Place the matching media files by the template of their metadata.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+No files are placed without QUERY, use e.g. "path=." to select all the files.
+Only the regular files are placed, so '--group-records' and '--archive' are not allowed,
+and the symbolic links and the directories are reported as errors.
+
+The '--template' is a text/template of Go (https://pkg.go.dev/text/template) rendering the destination path relative to '--dest'.
+The 'key' of the metadata are available as {{.key}} and the missing 'key' are empty.
+The values are sanitized: path separators and the characters not allowed on some filesystems (<>:"|?*) are replaced with _.
+The empty directories in the path are removed.
+The following functions are available in addition to the builtin functions:
+
+- get KEY: The value of KEY, e.g. {{get "album_artist"}}
+- default DEFAULT VALUE: DEFAULT if VALUE is empty, e.g. {{default "Unknown" .artist}}
+- num VALUE: The leading number of VALUE, e.g. 3 of 3/12
+
+The operations are output in jsonl format with the following fields:
+
+- source: The path of the file
+- dest: The destination path
+- mode: move, copy, hardlink or symlink
+- skip: The reason why the file is skipped
+- overwrite: true if the existing file is replaced
+- error: The error of the operation
+
+Using the '--undo-log' option, the executed operations are appended to the file,
+and 'fflist organize undo' reverts them. The files replaced by the 'overwrite' collision policy cannot be restored.
+
+Examples:
+# show the plan to move the mp3 files in ~/Downloads into ~/Music
+fflist organize -r ~/Downloads --dest ~/Music --dry-run \
+  --template '{{.artist}}/{{.album}}/{{printf "%02s" (num .track)}} {{.title}}{{.ext}}' 'ext=\.mp3$'
+# move them and keep the undo log
+fflist organize -r ~/Downloads --dest ~/Music --undo-log undo.jsonl \
+  --template '{{.artist}}/{{.album}}/{{printf "%02s" (num .track)}} {{.title}}{{.ext}}' 'ext=\.mp3$'
+# undo it
+fflist organize undo undo.jsonl
+# link the files by genre
+fflist organize -r ~/Music --dest ~/Genres --mode symlink --collision rename \
+  --template '{{default "Unknown" .genre}}/{{.name}}' path=.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if records, _ := cmd.Flags().GetBool("group-records"); records {
+			return fmt.Errorf("%w: group records are not files", errArgument)
+		}
+		if archive, _ := cmd.Flags().GetBool("archive"); archive {
+			return fmt.Errorf("%w: archive members cannot be organized", errArgument)
+		}
+		var (
+			text, _      = cmd.Flags().GetString("template")
+			dest, _      = cmd.Flags().GetString("dest")
+			modeArg, _   = cmd.Flags().GetString("mode")
+			policyArg, _ = cmd.Flags().GetString("collision")
+			dryRun, _    = cmd.Flags().GetBool("dry-run")
+			undoLog, _   = cmd.Flags().GetString("undo-log")
+		)
+		tmpl, err := layout.New(text)
+		if err != nil {
+			return err
+		}
+		mode, err := organize.ParseMode(modeArg)
+		if err != nil {
+			return err
+		}
+		policy, err := organize.ParsePolicy(policyArg)
+		if err != nil {
+			return err
+		}
+
+		selector, root, err := parseSelector(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		var undoW io.Writer
+		if undoLog != "" && !dryRun {
+			f, err := os.OpenFile(undoLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			undoW = f
+		}
+
+		return run.NewOrganize(
+			source,
+			selector,
+			organize.NewPlanner(tmpl, run.ExpandEnvAll(dest)[0], mode, policy),
+			os.Stdout,
+			undoW,
+			dryRun,
+			getVerbose(cmd),
+		).Run(cmd.Context())
+	},
+}
+
+var organizeUndoCmd = &cobra.Command{
+	Use:   "undo UNDO_LOG",
+	Short: `Revert the operations of the undo log`,
+	Long: `Revert the operations of the undo log in reverse order.
+
+The moved files are moved back, and the copied or linked files are removed.
+The reverted operations are output in jsonl format.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %w", errArgument, err)
+		}
+		defer f.Close()
+		return run.UndoOrganize(f, os.Stdout)
+	},
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(queriesCmd)
+	queriesCmd.AddCommand(queriesListCmd)
+	configFlag(queriesListCmd)
+}
+
+var queriesCmd = &cobra.Command{
+	Use:   "queries",
+	Short: `Manage the named queries`,
+	Long: `Manage the named queries.
+
+The named queries are defined by 'queries' of the config or the file specified by the '--queries' option,
+and referenced by @name in QUERY of the commands and in the other named queries.
+The named query is the same as QUERY of the 'query' command, and it is evaluated as a condition.
+The config has the following format:
+
+queries:
+  lossless:
+    - ext=\.(flac|wav|aiff)$
+  video-hd:
+    - streams.0.height=^(720|1080)$
+  untagged:
+    - artist=^$
+    - or
+    - title=^$
+  lossless-untagged:
+    - "@lossless"
+    - "@untagged"
+
+The file of the '--queries' option has the content of 'queries' at the top level.
+The named queries of the file win over the config, and they can reference each other.
+The references to unknown names and the cyclic references are errors.`,
+}
+
+var queriesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: `List the named queries`,
+	Long: `List the named queries in jsonl format with the following fields:
+
+- name: The name of the query
+- query: The query
+
+Examples:
+# list the named queries of the config
+fflist queries list -c config.yml
+# list the named queries of the file
+fflist queries list --queries queries.yml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		queries, err := getNamedQueries(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(queries)) {
+			fmt.Printf("%s\n", logx.Jsonify(map[string]any{
+				"name":  name,
+				"query": queries[name],
+			}))
+		}
+		return nil
+	},
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"context"
-	"errors"
+	"fmt"
 	"os"
 
-	"github.com/berquerant/fflist/meta"
 	"github.com/berquerant/fflist/query"
 	"github.com/berquerant/fflist/run"
-	"github.com/berquerant/fflist/worker"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +17,12 @@ func init() {
 	configFlag(queryCmd)
 	createIndexFlag(queryCmd)
 	readIndexFlag(queryCmd)
+	cueFlag(queryCmd)
+	walkFlag(queryCmd)
+	probeFailureRecordFlag(queryCmd)
+	chapterFlag(queryCmd)
+	groupFlag(queryCmd)
+	execFlag(queryCmd)
 }
 
 var queryCmd = &cobra.Command{
@@ -42,11 +45,28 @@ The available 'key' include the following:
 - path: The path of the file
 - mode: The file permissions (in octal)
 - mod_time: The last modification time of the file
+- mod_time_rfc3339: mod_time in RFC3339
+- mod_time_unix: mod_time as a Unix epoch
 - size: The file size (in bytes)
 - dir: All but the last element of path
 - ext: The file name extension
 - basename: name but ext
 - basepath: path but ext
+- is_symlink: true if the file is a symbolic link
+- link_target: The target of the symbolic link
+
+On Linux, the following 'key' are also available:
+
+- atime: The last access time of the file (in RFC3339)
+- ctime: The last status change time of the file (in RFC3339)
+- birth_time: The creation time of the file (in RFC3339), if the filesystem supports it
+- inode
+- dev: The device number
+- nlink: The number of hard links
+- uid
+- gid
+- owner: The name of uid
+- group: The name of gid
 
 Depending on the type of media file, the following 'key' may also be available:
 
@@ -57,7 +77,57 @@ Depending on the type of media file, the following 'key' may also be available:
 
 Note: All metadata values are interpreted as strings.
 
-To check which 'key' are actually available, please use the 'fflist debug' command or the '--verbose' option.
+To check which 'key' are actually available, please use the 'fflist keys' command, the 'fflist debug' command or the '--verbose' option.
+To check why a file matches or not, please use the 'fflist explain' command.
+
+Using the '--cue' option, a file backed by a CUE sheet is expanded into virtual tracks.
+The CUE sheet is read from the embedded 'cuesheet' tag or the sidecar file (e.g. album.cue for album.flac).
+The sidecar file expands only the audio file whose name matches FILE of the sheet,
+or the audio file with the same base name if the sheet has only one FILE (e.g. album.flac for FILE "album.wav").
+Each track is selected on its own and has the following 'key':
+
+- path: The identifier of the track, e.g. album.flac#track=3
+- source_path: The path of the file
+- title
+- performer
+- track: The track number
+- start: The start time of the track (in seconds)
+- duration: The duration of the track (in seconds), missing for the last track if the duration of the file is unknown
+
+Using the '--archive' option, the members of zip and tar archives are listed instead of the archives,
+e.g. bundle.zip!/dir/track.wav. The members are probed by streaming them to the stdin of ffprobe.
+The members have the following 'key' in addition to the keys from the archive headers:
+
+- archive: The path of the archive
+- archive_member: The name of the member
+
+Using the '--chapters' option, the following 'key' are available in addition to the chapters.N.* keys:
+
+- chapter_count: The number of chapters
+- chapter_title: The titles of the chapters, as a JSON array
+- chapter_start: The start times of the chapters (in seconds), as a JSON array
+- chapter_end: The end times of the chapters (in seconds), as a JSON array
+
+The values of the multi-valued keys can be matched with the quotes, e.g. 'chapter_title="Prologue"'.
+Using the '--chapter-records' option, one record per chapter is output with the following 'key':
+
+- chapter.index: The index of the chapter, starting from 0
+- chapter.title
+- chapter.start: The start time of the chapter (in seconds)
+- chapter.end: The end time of the chapter (in seconds)
+
+Using the '--group' option, the files are grouped into albums by the directory (dir) or album and album_artist (album),
+and the following 'key' are added to the files in the groups:
+
+- album.key: The identifier of the group, the directory or album_artist/album
+- album.track_count: The number of the files in the group
+- album.total_duration: The sum of the duration of the files (in seconds)
+- album.missing_tracks: The missing track numbers per disc, as a JSON array, e.g. ["2-3"] (disc 2, track 3) if the group has several discs
+- album.formats: The extensions of the files, as a JSON array
+- album.mixed_formats: true if the group has several extensions
+
+Using the '--group-records' option, one record per group is output instead of the files, and the path of the record is album.key.
+Note that the grouping starts after all the files are probed.
 
 Using sh 'key' allows you to execute a sh script and output the file path only if the exit status is 0.
 The value of sh 'key' is the main body of the script, and environment variables are available.
@@ -66,6 +136,40 @@ For example, by writing a QUERY like the following, you can output only the file
 
   'sh=jq "select((.size|tonumber) > 8000000).name" -r | grep -E ".+" -q'
 
+Using shco 'key' runs the script as coprocesses instead of once per file, at most one per worker ('--worker').
+Each coprocess receives the metadata of each file as a line of jsonl from standard input,
+and should write one line per file to standard output, "true" or "0" to output the file path.
+The script should flush its output per line, and it is restarted if it crashes or exceeds the '--shco-timeout'.
+The above example can be written as follows:
+
+  'shco=jq --unbuffered "(.size|tonumber) > 8000000"'
+
+Using expr 'key' evaluates an expression over the metadata in process, without any external commands.
+The keys are available as variables, and missing keys are nil.
+The values are numbers if they are numbers, times if they are RFC3339 or 'YYYY-MM-DD hh:mm:ss', otherwise strings.
+The evaluation errors are logged as warnings and the files are not selected.
+The keys that are not valid identifiers or conflict with the builtin functions are available by $env, e.g. $env["streams.0.codec_name"], $env.duration.
+The expression supports arithmetic, string functions, regular expressions by 'matches', lists by 'in', and date math.
+See https://expr-lang.org/docs/language-definition for the details.
+Environment variables are not expanded in the value of expr 'key'.
+The above example can be written as follows:
+
+  'expr=size > 8000000'
+
+Other examples:
+
+  'expr=ext in [".mp3", ".flac"] && $env.duration > 600'
+  'expr=lower(artist) contains "quartet"'
+  'expr=now() - mod_time_rfc3339 < duration("168h")'
+
+Using the '--exec' option, the command is executed per matching file instead of outputting the path, like find -exec.
+The command is a sh script, and {} is replaced with the path. Do not quote {}, the path is passed safely as a parameter of the script.
+The metadata are available as environment variables FFLIST_KEY, where KEY is the upper case of 'key' and the characters other than alphanumerics are replaced with _,
+e.g. FFLIST_ARTIST, FFLIST_STREAMS_0_CODEC_NAME. The metadata are also passed to the standard input in json format.
+Using the '--exec-batch' option, the command is executed per batch of matching files, and {} is replaced with the paths, like find -exec {} +.
+The environment variables are not available, and the metadata of the batch are passed to the standard input in jsonl format.
+The summary of the exit statuses is output to the standard error at the end.
+
 Using the '--config' option allows you to specify the search directory and QUERY from a file.
 The file has the following format:
 
@@ -78,6 +182,21 @@ query:
   - - name=NAME2
     - artist=ARTIST
 
+probe: # optional
+  sections:
+    - streams
+  analyzeduration: 10M
+  probesize: "50000000"
+  args:
+    - -fflags
+    - +genpts
+lint: # optional, see 'fflist lint --help'
+  required:
+    - keys: [artist]
+queries: # optional, see 'fflist queries --help'
+  lossless:
+    - ext=\.(flac|wav)$
+
 or
 
 {
@@ -92,9 +211,18 @@ or
 Nested conditions are evaluated with AND, while top-level conditions are evaluated with OR.
 In the above example, it means 'name=NAME1 OR (name=NAME2 AND artist=ARTIST)'.
 
-When the '--config' option is specified, the '--root' option and QUERY arguments are ignored.
+'probe' configures the arguments of ffprobe, the same as the '--probe-section', '--probe-analyzeduration', '--probe-probesize' and '--probe-arg' options.
+'sections' are shown in addition to format, e.g. streams, chapters, programs, and each of them can specify entries like -show_entries (e.g. stream=codec_name).
+The values of the sections are flattened into namespaced keys, e.g. streams.0.codec_name.
+
+QUERY can reference the named queries by @name, e.g. '@lossless artist=ARTIST'.
+The named queries are defined by 'queries' of the config or the file specified by the '--queries' option.
+
+When the '--config' option is specified, the '--root' option is ignored,
+and QUERY arguments are used instead of config.query if they are specified,
+e.g. '-c config.yml @lossless' selects the files by the named query of the config.
 
-You can use environment variables (e.g. '$VARNAME') in the file specified by the --config option, as well as in the --root option and QUERY arguments.
+You can use environment variables (e.g. '$VARNAME') in the file specified by the --config option, as well as in the --root option and QUERY arguments, except for expr 'key'.
 
 Exmaples:
 # in ~/Music, match name
@@ -105,6 +233,10 @@ fflist query -r ~/Music 'artist=ARTIST' 'genre=GENRE'
 fflist query -r ~/Music name=NAME1 OR name=NAME2 artist=ARTIST
 # read paths from stdin, match name
 fflist query -r - name=NAME < path.list
+# read NUL-separated paths from stdin, match name
+find ~/Music -type f -print0 | fflist query -r - -0 name=NAME
+# read paths and pre-known metadata from stdin, match artist
+echo '{"path":"/music/a.mp3","artist":"ARTIST"}' | fflist query -r - --stdin-format jsonl artist=ARTIST
 # create index of ~/Music
 fflist query -r ~/Music --createIndex > index
 # in the index, match name
@@ -112,77 +244,76 @@ fflist query --readIndex index 'name=NAME'
 # create index from config
 fflist query -c config.yml --createIndex > index
 # read index and query config
-fflist query -c config.yml --readIndex index`,
+fflist query -c config.yml --readIndex index
+# in ~/Music, follow symbolic links, match name
+fflist query -r ~/Music --follow-symlinks 'name=NAME'
+# in ~/Music, list the files up to 2 levels below, skip directories containing .nomedia
+fflist query -r ~/Music --max-depth 2 --skip-marker .nomedia 'name=NAME'
+# in /mnt/nas, read 16 directories concurrently, match name
+fflist query -r /mnt/nas --walk-worker 16 'name=NAME'
+# in ~/Samples, match the members of archives
+fflist query -r ~/Samples --archive 'ext=\.wav$'
+# reproduce a query with the metadata from the index instead of ffprobe
+fflist query -r ~/Music --probe-fixture index 'name=NAME'
+# in ~/Music, list the files ffprobe cannot read
+fflist query -r ~/Music --probe-timeout 30s --probe-retry 2 --probe-failure-record 'probe_error=.'
+# in ~/Videos, match the codec of the first stream
+fflist query -r ~/Videos --probe-section streams 'streams.0.codec_name=h264'
+# in ~/Audiobooks, find a chapter by its title
+fflist query -r ~/Audiobooks --chapter-records -v 'chapter.title=Prologue'
+# in ~/Music, select files by a long-lived script
+fflist query -r ~/Music 'shco=jq --unbuffered "(.size|tonumber) > 8000000"'
+# in ~/Music, match the files larger than 8MB and modified within a week
+fflist query -r ~/Music 'expr=size > 8000000 && now() - mod_time_rfc3339 < duration("168h")'
+# in ~/Music, list the albums with gaps in track numbers
+fflist query -r ~/Music --group album --group-records 'album.missing_tracks=.'
+# in ~/Music, list the files in the directories mixing mp3 and flac
+fflist query -r ~/Music --group dir 'album.mixed_formats=true'
+# in ~/Music, convert the flac files of the artist to mp3 in 4 processes
+fflist query -r ~/Music --exec-jobs 4 --exec 'ffmpeg -i {} -q:a 2 "${FFLIST_BASEPATH}.mp3"' 'ext=\.flac$' 'artist=ARTIST'
+# in ~/Music, play the files of the album by a single command
+fflist query -r ~/Music --exec-batch 'mpv {} +' 'album=ALBUM'
+# in ~/Music, match title of the tracks of CUE sheets
+fflist query -r ~/Music --cue 'title=TITLE'`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			selector query.Selector
-			root     []string
-		)
-
-		config, err := getConfig(cmd)
-		switch {
-		case err == nil:
-			x, err := config.ParseQuery()
-			if err != nil {
-				return err
-			}
-			selector = x
-			root = config.Root
-		case errors.Is(err, errNoConfig):
-			x, err := run.ParseQueryCommandLine(args)
-			if err != nil {
-				return err
-			}
-			selector = x
-			root = getRoot(cmd)
-		default:
+		selector, root, err := parseSelector(cmd, args)
+		if err != nil {
 			return err
 		}
+		defer query.Close(selector)
 
 		var (
 			verbose = getVerbose(cmd)
 		)
 
-		if indexFiles := getReadIndex(cmd); len(indexFiles) > 0 {
-			return readIndex(cmd.Context(), indexFiles, run.NewWriter(os.Stdout, selector, verbose))
+		execConfig, err := getExecConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if execConfig != nil && getCreateIndex(cmd) {
+			return fmt.Errorf("%w: '--createIndex' cannot be used with '--exec'", errArgument)
 		}
 
-		if getCreateIndex(cmd) {
+		// the index is read as is
+		if getCreateIndex(cmd) && len(getReadIndex(cmd)) == 0 {
 			// probe all files
 			selector = query.NewTrueSelector()
 			// dump metadata
 			verbose = true
 		}
 
-		newWalker, err := newWalkerFactory(root)
+		source, closer, err := newSource(cmd, root)
 		if err != nil {
 			return err
 		}
+		defer closer.Close()
 
-		var (
-			writer      = run.NewWriter(os.Stdout, selector, verbose)
-			walkWorker  = worker.NewWalker(newWalker)
-			probeWorker = worker.NewProbe(meta.NewProber(getProbe(cmd)), getProbeWorkerNum(cmd))
-		)
+		if execConfig != nil {
+			return run.NewExec(source, selector, *execConfig, os.Stdout, os.Stderr, verbose).Run(cmd.Context())
+		}
 
-		q := run.NewQuery(
-			root,
-			walkWorker,
-			probeWorker,
-			writer,
-		)
+		writer := run.NewWriter(os.Stdout, selector, verbose)
 
-		return q.Run(cmd.Context())
+		return run.NewQuery(source, writer).Run(cmd.Context())
 	},
 }
-
-func readIndex(ctx context.Context, indexFiles []string, writer *run.Writer) error {
-	r, err := newIndexReader(indexFiles)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-
-	q := run.NewIndexQuery(r.Reader(), writer)
-	return q.Run(ctx)
-}
@@ -7,11 +7,18 @@ import (
 	"log/slog"
 	"os"
 	"slices"
+	"time"
 
+	"github.com/berquerant/fflist/cue"
+	"github.com/berquerant/fflist/group"
+	"github.com/berquerant/fflist/info"
 	"github.com/berquerant/fflist/iox"
 	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
 	"github.com/berquerant/fflist/run"
 	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +26,19 @@ func init() {
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug logs")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Quiet logs except ERROR")
 	rootCmd.PersistentFlags().StringP("probe", "p", "ffprobe", "Media analyzer command")
+	rootCmd.PersistentFlags().String("probe-fixture", "", `Read metadata from the file instead of the media analyzer.
+The format is the same as the index, each line should have the path.
+The metadata of the files, e.g. size and mod_time, win over the file`)
+	rootCmd.PersistentFlags().Duration("probe-timeout", 0, "Timeout of the media analyzer per file. 0 means no timeout")
+	rootCmd.PersistentFlags().Int("probe-retry", 0, "Max retries of the media analyzer on transient errors, e.g. I/O errors. Timeouts are not retried")
+	rootCmd.PersistentFlags().Duration("probe-retry-interval", time.Second, "Interval between retries of the media analyzer")
+	rootCmd.PersistentFlags().StringSlice("probe-section", nil, `Sections to show in addition to format, e.g. streams, chapters, programs.
+The values are flattened into namespaced keys, e.g. streams.0.codec_name`)
+	rootCmd.PersistentFlags().String("probe-analyzeduration", "", "-analyzeduration of the media analyzer")
+	rootCmd.PersistentFlags().String("probe-probesize", "", "-probesize of the media analyzer")
+	rootCmd.PersistentFlags().StringArray("probe-arg", nil, "Extra argument of the media analyzer")
+	rootCmd.PersistentFlags().Duration("shco-timeout", run.DefaultCoprocessTimeout, "Timeout of the shco script per file. 0 means no timeout")
+	rootCmd.PersistentFlags().String("queries", "", "Named queries file. The named queries are referenced by @name in QUERY, in addition to config.queries")
 }
 
 func getProbe(cmd *cobra.Command) string {
@@ -26,6 +46,42 @@ func getProbe(cmd *cobra.Command) string {
 	return x
 }
 
+func newProber(cmd *cobra.Command) (meta.Prober, error) {
+	fixture, _ := cmd.Flags().GetString("probe-fixture")
+	if fixture == "" {
+		return newFFProber(cmd)
+	}
+
+	f, err := os.Open(fixture)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return meta.ReadFixture(f)
+}
+
+// newFFProber returns the prober reading the files, ignoring the fixture.
+func newFFProber(cmd *cobra.Command) (*meta.FFProber, error) {
+	c, err := newProbeConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		timeout, _       = cmd.Flags().GetDuration("probe-timeout")
+		retry, _         = cmd.Flags().GetInt("probe-retry")
+		retryInterval, _ = cmd.Flags().GetDuration("probe-retry-interval")
+	)
+	return meta.NewProber(
+		getProbe(cmd),
+		meta.WithTimeout(timeout),
+		meta.WithRetry(retry, retryInterval),
+		meta.WithSections(c.Sections...),
+		meta.WithAnalyzeDuration(c.AnalyzeDuration),
+		meta.WithProbeSize(c.ProbeSize),
+		meta.WithArgs(c.Args...),
+	), nil
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "fflist",
 	Short: `Select media file resources`,
@@ -67,7 +123,7 @@ func getVerbose(cmd *cobra.Command) bool {
 }
 
 func probeWorkerNumFlag(cmd *cobra.Command) {
-	cmd.Flags().IntP("worker", "w", 8, "Probe worker num")
+	cmd.Flags().IntP("worker", "w", 8, "Probe worker num. The selectors run in parallel by the same number")
 }
 
 func getProbeWorkerNum(cmd *cobra.Command) int {
@@ -79,7 +135,7 @@ func getProbeWorkerNum(cmd *cobra.Command) int {
 }
 
 func createIndexFlag(cmd *cobra.Command) {
-	cmd.Flags().Bool("createIndex", false, "Dump all metadata. Equivalent to '--verbose' and ignoring all QUERY")
+	cmd.Flags().Bool("createIndex", false, "Dump all metadata. Equivalent to '--verbose' and ignoring all QUERY. Ignored with '--readIndex'")
 }
 
 func getCreateIndex(cmd *cobra.Command) bool {
@@ -100,6 +156,92 @@ func getReadIndex(cmd *cobra.Command) []string {
 	return x
 }
 
+func cueFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("cue", false, "Expand files backed by CUE sheets into virtual tracks")
+}
+
+func getCue(cmd *cobra.Command) bool {
+	x, _ := cmd.Flags().GetBool("cue")
+	return x
+}
+
+func chapterFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("chapters", false, "Probe chapters. Equivalent to '--probe-section chapters'")
+	cmd.Flags().Bool("chapter-records", false, "Output one record per chapter. Implies '--chapters'")
+}
+
+func getChapters(cmd *cobra.Command) bool {
+	x, _ := cmd.Flags().GetBool("chapters")
+	return x || getChapterRecords(cmd)
+}
+
+func getChapterRecords(cmd *cobra.Command) bool {
+	x, _ := cmd.Flags().GetBool("chapter-records")
+	return x
+}
+
+func groupFlag(cmd *cobra.Command) {
+	cmd.Flags().String("group", "", `Group files and add album.* keys.
+dir: group by the directory
+album: group by album and album_artist`)
+	cmd.Flags().Bool("group-records", false, "Output one record per group instead of the files. Requires '--group'")
+}
+
+// getGroup returns the key to group files, empty means no grouping.
+func getGroup(cmd *cobra.Command) (group.By, bool, error) {
+	x, _ := cmd.Flags().GetString("group")
+	records, _ := cmd.Flags().GetBool("group-records")
+	if x == "" {
+		if records {
+			return "", false, fmt.Errorf("%w: '--group-records' requires '--group'", errArgument)
+		}
+		return "", false, nil
+	}
+	by, err := group.ParseBy(x)
+	if err != nil {
+		return "", false, err
+	}
+	return by, records, nil
+}
+
+func execFlag(cmd *cobra.Command) {
+	cmd.Flags().String("exec", "", "Execute the sh script per matching file, {} is replaced with the path")
+	cmd.Flags().String("exec-batch", "", "Execute the sh script per batch of matching files, {} is replaced with the paths")
+	cmd.Flags().Int("exec-batch-size", 100, "Max number of the files per command of '--exec-batch'")
+	cmd.Flags().Int("exec-jobs", 1, "Max number of the commands running concurrently")
+	cmd.Flags().Bool("exec-fail-fast", false, "Stop at the first failure of the commands")
+}
+
+// getExecConfig returns nil if neither '--exec' nor '--exec-batch' are specified.
+func getExecConfig(cmd *cobra.Command) (*run.ExecConfig, error) {
+	var (
+		command, _   = cmd.Flags().GetString("exec")
+		batch, _     = cmd.Flags().GetString("exec-batch")
+		batchSize, _ = cmd.Flags().GetInt("exec-batch-size")
+		jobs, _      = cmd.Flags().GetInt("exec-jobs")
+		failFast, _  = cmd.Flags().GetBool("exec-fail-fast")
+	)
+	switch {
+	case command != "" && batch != "":
+		return nil, fmt.Errorf("%w: '--exec' and '--exec-batch' cannot be used together", errArgument)
+	case command != "":
+		batchSize = 0
+	case batch != "":
+		if batchSize < 1 {
+			return nil, fmt.Errorf("%w: '--exec-batch-size' should be positive", errArgument)
+		}
+		command = batch
+	default:
+		return nil, nil
+	}
+	return &run.ExecConfig{
+		Command:   command,
+		BatchSize: batchSize,
+		Jobs:      jobs,
+		FailFast:  failFast,
+	}, nil
+}
+
 var (
 	errNoConfig = errors.New("NoConfig")
 )
@@ -130,14 +272,278 @@ var (
 	errArgument = errors.New("Argument")
 )
 
-func newWalkerFactory(args []string) (func() walk.Walker, error) {
+// newProbeConfig returns config.probe overwritten by the flags.
+func newProbeConfig(cmd *cobra.Command) (*run.ProbeConfig, error) {
+	var c run.ProbeConfig
+	config, err := getConfig(cmd)
+	switch {
+	case err == nil:
+		c = config.Probe
+	case errors.Is(err, errNoConfig):
+	default:
+		return nil, err
+	}
+
+	var (
+		sections, _        = cmd.Flags().GetStringSlice("probe-section")
+		analyzeDuration, _ = cmd.Flags().GetString("probe-analyzeduration")
+		probeSize, _       = cmd.Flags().GetString("probe-probesize")
+		args, _            = cmd.Flags().GetStringArray("probe-arg")
+	)
+	c.Sections = append(c.Sections, sections...)
+	if getChapters(cmd) {
+		c.Sections = append(c.Sections, "chapters")
+	}
+	c.Args = append(c.Args, args...)
+	if analyzeDuration != "" {
+		c.AnalyzeDuration = analyzeDuration
+	}
+	if probeSize != "" {
+		c.ProbeSize = probeSize
+	}
+	return &c, nil
+}
+
+func probeFailureRecordFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("probe-failure-record", false, "Output the files failed to probe with probe_error and probe_stderr keys")
+}
+
+func newProbeOptions(cmd *cobra.Command) []worker.ProbeOption {
+	failureRecord, _ := cmd.Flags().GetBool("probe-failure-record")
+	r := []worker.ProbeOption{
+		worker.WithFailureRecords(failureRecord),
+	}
+	if getCue(cmd) {
+		r = append(r, worker.WithExpanders(cue.NewExpander()))
+	}
+	if getChapterRecords(cmd) {
+		r = append(r, worker.WithExpanders(info.NewChapterExpander()))
+	}
+	return r
+}
+
+func walkFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("follow-symlinks", false, "Follow symbolic links. Files reached through several links are listed only once")
+	cmd.Flags().Bool("one-file-system", false, "Do not descend directories on other filesystems")
+	cmd.Flags().Int("max-depth", -1, "Descend at most n levels of directories below the root. Negative means no limit")
+	cmd.Flags().Int("min-depth", 0, "Do not list files at levels less than n. The files directly under the root are at level 1")
+	cmd.Flags().Bool("skip-hidden", false, "Skip hidden directories")
+	cmd.Flags().StringSlice("skip-marker", nil, "Skip directories containing the file, e.g. .nomedia")
+	cmd.Flags().Int("walk-worker", 0, "Number of directories read concurrently per root. 0 means walking sequentially")
+	cmd.Flags().Bool("ordered", false, "Keep the walk order deterministic when walk-worker is specified")
+	cmd.Flags().String("stdin-format", string(walk.LineInput), fmt.Sprintf(
+		`Format of paths from stdin.
+%s: newline-separated paths
+%s: NUL-separated paths, e.g. find -print0
+%s: JSON object per line, with path and optional pre-known metadata`,
+		walk.LineInput, walk.NullInput, walk.JSONLInput,
+	))
+	cmd.Flags().BoolP("null", "0", false, fmt.Sprintf("Equivalent to '--stdin-format %s'", walk.NullInput))
+	cmd.Flags().Int("stdin-buffer", walk.DefaultInputBufferSize, "Max size of a path or a line from stdin in bytes")
+	cmd.Flags().Bool("archive", false, "Walk inside zip and tar archives")
+}
+
+func newWalkOptions(cmd *cobra.Command) ([]walk.Option, error) {
+	followSymlinks, _ := cmd.Flags().GetBool("follow-symlinks")
+	oneFileSystem, _ := cmd.Flags().GetBool("one-file-system")
+	maxDepth, _ := cmd.Flags().GetInt("max-depth")
+	minDepth, _ := cmd.Flags().GetInt("min-depth")
+	skipHidden, _ := cmd.Flags().GetBool("skip-hidden")
+	skipMarker, _ := cmd.Flags().GetStringSlice("skip-marker")
+	ordered, _ := cmd.Flags().GetBool("ordered")
+	stdinFormat, _ := cmd.Flags().GetString("stdin-format")
+	stdinBuffer, _ := cmd.Flags().GetInt("stdin-buffer")
+	if null, _ := cmd.Flags().GetBool("null"); null {
+		stdinFormat = string(walk.NullInput)
+	}
+	inputFormat, err := walk.ParseInputFormat(stdinFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	r := []walk.Option{
+		walk.WithFollowSymlinks(followSymlinks),
+		walk.WithOneFileSystem(oneFileSystem),
+		// dedup files across roots
+		walk.WithVisited(walk.NewVisited()),
+		walk.WithMaxDepth(maxDepth),
+		walk.WithMinDepth(minDepth),
+		walk.WithOrdered(ordered),
+		walk.WithInputFormat(inputFormat),
+		walk.WithInputBufferSize(stdinBuffer),
+	}
+	if skipHidden {
+		r = append(r, walk.WithSkipDir(walk.SkipHidden()))
+	}
+	for _, x := range skipMarker {
+		r = append(r, walk.WithSkipDir(walk.SkipMarker(x)))
+	}
+	return r, nil
+}
+
+func newWalkerFactory(cmd *cobra.Command, args []string) (func() walk.Walker, error) {
+	opt, err := newWalkOptions(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		walkWorker, _ = cmd.Flags().GetInt("walk-worker")
+		archive, _    = cmd.Flags().GetBool("archive")
+		newFile       = func() walk.Walker {
+			if walkWorker > 0 {
+				return walk.NewParallel(walkWorker, opt...)
+			}
+			return walk.NewFile(opt...)
+		}
+		wrap = func(w walk.Walker) walk.Walker {
+			if archive {
+				return walk.NewArchive(w)
+			}
+			return w
+		}
+	)
 	if !slices.Contains(args, stdinMark) {
-		return func() walk.Walker { return walk.NewFile() }, nil
+		return func() walk.Walker { return wrap(newFile()) }, nil
 	}
 	if len(args) != 1 {
 		return nil, fmt.Errorf("%w: no other roots can be specified when using - (stdin)", errArgument)
 	}
-	return func() walk.Walker { return walk.NewReader(os.Stdin, walk.NewFile()) }, nil
+	return func() walk.Walker { return wrap(walk.NewReader(os.Stdin, newFile(), opt...)) }, nil
+}
+
+func newParseOptions(cmd *cobra.Command) ([]run.ParseOption, error) {
+	timeout, _ := cmd.Flags().GetDuration("shco-timeout")
+	queries, err := getNamedQueries(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return []run.ParseOption{
+		run.WithCoprocessTimeout(timeout),
+		run.WithNamedQueries(queries),
+	}, nil
+}
+
+// getNamedQueries returns the named queries of the config and the file specified by '--queries'.
+// The file wins over the config.
+func getNamedQueries(cmd *cobra.Command) (map[string][]string, error) {
+	queries, err := readNamedQueries(cmd)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getConfig(cmd)
+	switch {
+	case err == nil:
+		return config.NamedQueries(queries)
+	case errors.Is(err, errNoConfig):
+		if err := run.CheckNamedQueries(queries); err != nil {
+			return nil, err
+		}
+		return queries, nil
+	default:
+		return nil, err
+	}
+}
+
+// readNamedQueries returns the named queries from the file specified by '--queries'.
+func readNamedQueries(cmd *cobra.Command) (map[string][]string, error) {
+	file, _ := cmd.Flags().GetString("queries")
+	if file == "" {
+		return nil, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return run.ParseNamedQueries(f)
+}
+
+// parseSelector returns the selector and the roots from the config or QUERY arguments.
+// QUERY arguments win over config.query.
+// The selector should be closed by query.Close.
+func parseSelector(cmd *cobra.Command, args []string) (query.Selector, []string, error) {
+	opt, err := newParseOptions(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := getConfig(cmd)
+	switch {
+	case err == nil && len(args) > 0:
+		x, err := run.ParseQueryCommandLine(args, opt...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return x, config.Root, nil
+	case err == nil:
+		x, err := config.ParseQuery(opt...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return x, config.Root, nil
+	case errors.Is(err, errNoConfig):
+		x, err := run.ParseQueryCommandLine(args, opt...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return x, getRoot(cmd), nil
+	default:
+		return nil, nil, err
+	}
+}
+
+// parseSelectorOrAll is parseSelector but selects all the files if neither the config nor QUERY are specified.
+func parseSelectorOrAll(cmd *cobra.Command, args []string) (query.Selector, []string, error) {
+	if _, err := getConfig(cmd); errors.Is(err, errNoConfig) && len(args) == 0 {
+		return query.NewTrueSelector(), getRoot(cmd), nil
+	}
+	return parseSelector(cmd, args)
+}
+
+func newWorkers(cmd *cobra.Command, root []string) (*worker.Walker, *worker.Prober, error) {
+	newWalker, err := newWalkerFactory(cmd, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	prober, err := newProber(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return worker.NewWalker(newWalker),
+		worker.NewProbe(prober, getProbeWorkerNum(cmd), newProbeOptions(cmd)...),
+		nil
+}
+
+// newSource returns the source from the index if '--readIndex' is specified, otherwise walking the roots.
+// The source is grouped if '--group' is specified.
+func newSource(cmd *cobra.Command, root []string) (run.Source, io.Closer, error) {
+	by, records, err := getGroup(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, closer, err := newBaseSource(cmd, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if by != "" {
+		source = run.NewGroupSource(source, by, records)
+	}
+	return source, closer, nil
+}
+
+func newBaseSource(cmd *cobra.Command, root []string) (run.Source, io.Closer, error) {
+	if indexFiles := getReadIndex(cmd); len(indexFiles) > 0 {
+		r, err := newIndexReader(indexFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		return run.NewIndexSource(r.Reader()), r, nil
+	}
+
+	walkWorker, probeWorker, err := newWorkers(cmd, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return run.NewWalkSource(root, walkWorker, probeWorker), io.NopCloser(nil), nil
 }
 
 func newIndexReader(args []string) (iox.ReaderAndCloser, error) {
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/syncdir"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	rootFlag(syncCmd)
+	verboseFlag(syncCmd)
+	probeWorkerNumFlag(syncCmd)
+	configFlag(syncCmd)
+	readIndexFlag(syncCmd)
+	walkFlag(syncCmd)
+	syncCmd.Flags().String("dest", "", "Destination directory")
+	syncCmd.Flags().StringSlice("base", nil, "Base directories of the relative paths. Default is '--root' or config.root")
+	syncCmd.Flags().Bool("delete", false, "Remove the files in the destination that do not match and were copied by the sync")
+	syncCmd.Flags().Bool("delete-untracked", false, "With '--delete', remove the files not recorded in the manifest too")
+	syncCmd.Flags().Bool("dry-run", false, "Output the actions without changing the destination")
+	_ = syncCmd.MarkFlagRequired("dest")
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [QUERY...]",
+	Short: `Make the destination directory contain the copies of the matching media files`,
+	Long: `Make the destination directory contain the copies of the matching media files.
+
+The QUERY and the options to search for files are the same as the 'query' command.
+No files are synced without QUERY, use e.g. "path=." to select all the files.
+
+The destination path mirrors the path relative to the '--base' directories.
+The new files are copied, and the changed files are updated.
+With the '--delete' option, the other files in the destination recorded in the manifest are removed,
+so that the destination contains the matching files and no stale copies.
+The files not recorded in the manifest, e.g. put by hand, are kept unless '--delete-untracked' is specified,
+then the destination contains exactly the matching files.
+The files are not removed if the search fails, any file fails to be probed or QUERY fails, e.g. timeouts of the scripts.
+The destination should not overlap the roots and the base directories.
+
+The manifest file (` + syncdir.ManifestName + `) in the destination records the source path, size, modification time and sha256 of the copied files.
+The files with the same size and modification time as the manifest are skipped without reading them,
+and the files with the same content are skipped too, so that re-runs copy only the changed files.
+
+The actions are output in jsonl format with the following fields:
+
+- action: add, update, skip or delete
+- source: The path of the file
+- dest: The destination path
+- reason: The reason why the file is skipped
+- error: The error of the action
+
+The summary is output to stderr.
+
+Examples:
+# show the actions to sync the favorite albums to the USB drive
+fflist sync -r ~/Music --dest /Volumes/USB/Music --delete --dry-run 'comment=favorite'
+# sync them
+fflist sync -r ~/Music --dest /Volumes/USB/Music --delete 'comment=favorite'
+# sync the files of the config
+fflist sync -c config.yml --dest /Volumes/USB/Music --delete`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			dest, _        = cmd.Flags().GetString("dest")
+			bases, _       = cmd.Flags().GetStringSlice("base")
+			deleteExtra, _ = cmd.Flags().GetBool("delete")
+			untracked, _   = cmd.Flags().GetBool("delete-untracked")
+			dryRun, _      = cmd.Flags().GetBool("dry-run")
+		)
+
+		if untracked && !deleteExtra {
+			return fmt.Errorf("%w: --delete-untracked requires --delete", errArgument)
+		}
+
+		selector, root, err := parseSelector(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer query.Close(selector)
+		if len(bases) == 0 {
+			bases = root
+		}
+		dirs := slices.Clone(bases)
+		if len(getReadIndex(cmd)) == 0 {
+			// the roots are walked
+			dirs = append(dirs, root...)
+		}
+		dirs = slices.DeleteFunc(run.ExpandEnvAll(dirs...), func(x string) bool {
+			return x == stdinMark
+		})
+		if err := syncdir.CheckDest(run.ExpandEnvAll(dest)[0], dirs); err != nil {
+			return fmt.Errorf("%w: %w", errArgument, err)
+		}
+		syncer, err := syncdir.New(
+			run.ExpandEnvAll(dest)[0],
+			run.ExpandEnvAll(bases...),
+			dryRun,
+		)
+		if err != nil {
+			return err
+		}
+
+		source, closer, err := newSource(cmd, root)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		return run.NewSync(
+			source,
+			selector,
+			syncer,
+			os.Stdout,
+			os.Stderr,
+			deleteExtra,
+			untracked,
+			getVerbose(cmd),
+		).Run(cmd.Context())
+	},
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	for _, c := range []*cobra.Command{tagSetCmd, tagUnsetCmd, tagRenameKeyCmd} {
+		tagCmd.AddCommand(c)
+		rootFlag(c)
+		verboseFlag(c)
+		probeWorkerNumFlag(c)
+		configFlag(c)
+		readIndexFlag(c)
+		walkFlag(c)
+		c.Flags().StringArray("where", nil, "QUERY to select the files to rewrite, e.g. --where artist=A --where or --where album=B")
+		c.Flags().Bool("dry-run", false, "Output the changes without rewriting the files")
+		c.Flags().Bool("force", false, "Rewrite the files having several hard links. The other links keep the original")
+		c.Flags().String("ffmpeg", "ffmpeg", "Command to rewrite the files")
+	}
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: `Rewrite the tags of the matching media files`,
+	Long: `Rewrite the tags of the matching media files.
+
+The files are selected by the '--where' options or the '--config' option, the same as QUERY of the 'query' command.
+The '--where' options are used instead of config.query if they are specified.
+No files are rewritten without them, use e.g. "--where path=." to select all the files.
+
+The tags are rewritten by ffmpeg without re-encoding (-c copy -metadata).
+The new file is written into a temporary file in the same directory,
+and replaces the original file after the media analyzer verifies the tags.
+The tag keys are compared ignoring case, e.g. ALBUM of flac.
+The tags are read from the files by the media analyzer, even if '--probe-fixture' or '--readIndex' is specified,
+and only the tags are changed, not the other metadata, e.g. name and duration.
+The rewritten files are verified by reading the tags too.
+The owner and the permissions of the original file are kept.
+The symbolic links are kept, and their targets are rewritten.
+The files having several hard links are not rewritten without '--force',
+because the replacement detaches the file from the other links.
+The members of archives cannot be rewritten, so '--archive' is not allowed.
+
+The changes are output in jsonl format with the following fields:
+
+- path: The path of the file
+- changes: The changes of the tags, key, before and after. null means the tag does not exist
+- dry_run: true if the '--dry-run' option is specified
+- error: The error if the file failed to be rewritten
+
+Requirements:
+- ffmpeg 7.1 https://ffmpeg.org/ffmpeg.html
+- ffprobe 7.1 https://ffmpeg.org/ffprobe.html`,
+}
+
+var tagSetCmd = &cobra.Command{
+	Use:   "set KEY=VALUE...",
+	Short: `Set the tags`,
+	Long: `Set the tags.
+
+An empty value removes the tag.
+
+Examples:
+# show the changes to set genre of the files of the artist
+fflist tag set -r ~/Music --where artist=ARTIST --dry-run genre=Jazz
+# set album_artist and date of the files in the album
+fflist tag set -r ~/Music --where 'album=^ALBUM$' album_artist=ARTIST date=2024`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tags := make(map[string]string, len(args))
+		for _, a := range args {
+			q, err := query.Parse(a)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errArgument, err)
+			}
+			tags[q.Key()] = q.Value()
+		}
+		return runTag(cmd, run.SetTags(tags))
+	},
+}
+
+var tagUnsetCmd = &cobra.Command{
+	Use:   "unset KEY...",
+	Short: `Remove the tags`,
+	Long: `Remove the tags.
+
+Examples:
+# remove comment of all the files
+fflist tag unset -r ~/Music --where path=. comment`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTag(cmd, run.UnsetTags(args))
+	},
+}
+
+var tagRenameKeyCmd = &cobra.Command{
+	Use:   "rename-key OLD NEW",
+	Short: `Move the values of the tags to another key`,
+	Long: `Move the values of the tags to another key.
+
+Examples:
+# move albumartist to album_artist
+fflist tag rename-key -r ~/Music --where albumartist=. albumartist album_artist`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTag(cmd, run.RenameTagKey(args[0], args[1]))
+	},
+}
+
+func runTag(cmd *cobra.Command, operation run.TagOperation) error {
+	if archive, _ := cmd.Flags().GetBool("archive"); archive {
+		return fmt.Errorf("%w: archive members cannot be rewritten", errArgument)
+	}
+	where, _ := cmd.Flags().GetStringArray("where")
+	selector, root, err := parseSelector(cmd, where)
+	if err != nil {
+		return err
+	}
+	defer query.Close(selector)
+	source, closer, err := newSource(cmd, root)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	// read the tags from the files, not from the fixture or the index
+	tagReader, err := newFFProber(cmd)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ffmpeg, _ = cmd.Flags().GetString("ffmpeg")
+		force, _  = cmd.Flags().GetBool("force")
+		dryRun, _ = cmd.Flags().GetBool("dry-run")
+	)
+	return run.NewTag(
+		source,
+		selector,
+		operation,
+		meta.NewTagWriter(ffmpeg),
+		tagReader,
+		os.Stdout,
+		force,
+		dryRun,
+		getVerbose(cmd),
+	).Run(cmd.Context())
+}
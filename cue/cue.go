@@ -0,0 +1,200 @@
+package cue
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrParse = errors.New("ParseCue")
+)
+
+// Sheet is a parsed CUE sheet.
+type Sheet struct {
+	Title     string
+	Performer string
+	Files     []*File
+}
+
+type File struct {
+	Name   string
+	Tracks []*Track
+}
+
+type Track struct {
+	Number    int
+	Title     string
+	Performer string
+	// Start is INDEX 01 of the track.
+	Start time.Duration
+}
+
+// FindFile returns the file entry for the media file name.
+// If the sheet has only one file, it also matches the name with another audio extension,
+// e.g. album.flac encoded from album.wav.
+func (s Sheet) FindFile(name string) (*File, bool) {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	if len(s.Files) == 1 && IsAudio(name) && stem(s.Files[0].Name) == stem(name) {
+		return s.Files[0], true
+	}
+	return nil, false
+}
+
+var audioExts = map[string]bool{
+	".aac":  true,
+	".aif":  true,
+	".aiff": true,
+	".alac": true,
+	".ape":  true,
+	".flac": true,
+	".m4a":  true,
+	".mp3":  true,
+	".ogg":  true,
+	".opus": true,
+	".tak":  true,
+	".tta":  true,
+	".wav":  true,
+	".wma":  true,
+	".wv":   true,
+}
+
+// IsAudio returns true if the name has an audio extension that a CUE sheet can describe.
+func IsAudio(name string) bool {
+	return audioExts[strings.ToLower(filepath.Ext(name))]
+}
+
+func stem(name string) string {
+	name = filepath.Base(filepath.ToSlash(name))
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Parse reads a CUE sheet.
+func Parse(r io.Reader) (*Sheet, error) {
+	var (
+		sheet   Sheet
+		file    *File
+		track   *Track
+		scanner = bufio.NewScanner(r)
+		lineNum int
+	)
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff") // BOM
+		}
+		if line == "" {
+			continue
+		}
+
+		command, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		fail := func(msg string) error {
+			return fmt.Errorf("%w: line %d: %s: %s", ErrParse, lineNum, msg, line)
+		}
+
+		switch strings.ToUpper(command) {
+		case "TITLE":
+			if track != nil {
+				track.Title = unquote(rest)
+			} else {
+				sheet.Title = unquote(rest)
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = unquote(rest)
+			} else {
+				sheet.Performer = unquote(rest)
+			}
+		case "FILE":
+			file = &File{
+				Name: unquote(trimFileType(rest)),
+			}
+			track = nil
+			sheet.Files = append(sheet.Files, file)
+		case "TRACK":
+			if file == nil {
+				return nil, fail("TRACK before FILE")
+			}
+			n, _, _ := strings.Cut(rest, " ")
+			number, err := strconv.Atoi(n)
+			if err != nil {
+				return nil, fail("invalid track number")
+			}
+			track = &Track{
+				Number: number,
+			}
+			file.Tracks = append(file.Tracks, track)
+		case "INDEX":
+			if track == nil {
+				return nil, fail("INDEX before TRACK")
+			}
+			n, t, _ := strings.Cut(rest, " ")
+			if n != "01" {
+				// ignore pregap and subindexes
+				continue
+			}
+			start, err := ParseTime(strings.TrimSpace(t))
+			if err != nil {
+				return nil, fail(err.Error())
+			}
+			track.Start = start
+		default:
+			// ignore REM, CATALOG, FLAGS, etc.
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Join(ErrParse, err)
+	}
+	return &sheet, nil
+}
+
+const (
+	framesPerSecond = 75
+)
+
+// ParseTime parses mm:ss:ff, ff is 1/75 second.
+func ParseTime(s string) (time.Duration, error) {
+	xs := strings.Split(s, ":")
+	if len(xs) != 3 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	var ns [3]int
+	for i, x := range xs {
+		n, err := strconv.Atoi(x)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %s", s)
+		}
+		ns[i] = n
+	}
+	return time.Duration(ns[0]*60+ns[1])*time.Second +
+		time.Duration(ns[2])*time.Second/framesPerSecond, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// trimFileType removes the file type (e.g. WAVE) of FILE command.
+func trimFileType(s string) string {
+	i := strings.LastIndex(s, " ")
+	if i < 0 || strings.HasSuffix(s, `"`) {
+		return s
+	}
+	return strings.TrimSpace(s[:i])
+}
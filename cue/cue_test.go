@@ -0,0 +1,156 @@
+package cue_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/cue"
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSheet = `REM GENRE Rock
+PERFORMER "ARTIST"
+TITLE "ALBUM"
+FILE "album.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "T1"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "T2"
+    PERFORMER "GUEST"
+    INDEX 00 03:00:00
+    INDEX 01 03:02:15
+`
+
+func TestParse(t *testing.T) {
+	t.Run("sheet", func(t *testing.T) {
+		got, err := cue.Parse(bytes.NewBufferString(testSheet))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, &cue.Sheet{
+			Title:     "ALBUM",
+			Performer: "ARTIST",
+			Files: []*cue.File{
+				{
+					Name: "album.flac",
+					Tracks: []*cue.Track{
+						{
+							Number: 1,
+							Title:  "T1",
+						},
+						{
+							Number:    2,
+							Title:     "T2",
+							Performer: "GUEST",
+							Start:     3*time.Minute + 2*time.Second + 200*time.Millisecond,
+						},
+					},
+				},
+			},
+		}, got)
+	})
+
+	for _, tc := range []struct {
+		title string
+		src   string
+	}{
+		{
+			title: "track before file",
+			src:   "TRACK 01 AUDIO",
+		},
+		{
+			title: "index before track",
+			src:   "FILE \"a.flac\" WAVE\nINDEX 01 00:00:00",
+		},
+		{
+			title: "invalid time",
+			src:   "FILE \"a.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := cue.Parse(bytes.NewBufferString(tc.src))
+			assert.ErrorIs(t, err, cue.ErrParse)
+		})
+	}
+}
+
+func TestExpander(t *testing.T) {
+	t.Run("not expandable", func(t *testing.T) {
+		got, err := cue.NewExpander().Expand(context.TODO(), info.New(meta.NewData(map[string]string{
+			"path": "/notexist/a.flac",
+		})))
+		assert.Nil(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("embedded", func(t *testing.T) {
+		got, err := cue.NewExpander().Expand(context.TODO(), info.New(meta.NewData(map[string]string{
+			"path":     "/music/album.flac",
+			"duration": "300.000000",
+			"cuesheet": testSheet,
+		})))
+		if !assert.Nil(t, err) || !assert.Equal(t, 2, len(got)) {
+			return
+		}
+		for _, tc := range []struct {
+			key   string
+			want1 string
+			want2 string
+		}{
+			{"path", "/music/album.flac#track=1", "/music/album.flac#track=2"},
+			{"source_path", "/music/album.flac", "/music/album.flac"},
+			{"title", "T1", "T2"},
+			{"performer", "ARTIST", "GUEST"},
+			{"track", "1", "2"},
+			{"start", "0.000000", "182.200000"},
+			{"duration", "182.200000", "117.800000"},
+		} {
+			v1, _ := got[0].Get(tc.key)
+			v2, _ := got[1].Get(tc.key)
+			assert.Equal(t, tc.want1, v1, tc.key)
+			assert.Equal(t, tc.want2, v2, tc.key)
+		}
+	})
+
+	t.Run("sidecar", func(t *testing.T) {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"album.cue":  strings.ReplaceAll(testSheet, "album.flac", "album.wav"),
+			"album.flac": "",
+			"album.log":  "",
+			"album.jpg":  "",
+		} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expand := func(name string) []*info.Metadata {
+			got, err := cue.NewExpander().Expand(context.TODO(), info.New(meta.NewData(map[string]string{
+				"path": filepath.Join(dir, name),
+			})))
+			assert.Nil(t, err, name)
+			return got
+		}
+
+		got := expand("album.flac")
+		if !assert.Equal(t, 2, len(got)) {
+			return
+		}
+		v, _ := got[0].Get("source_path")
+		assert.Equal(t, filepath.Join(dir, "album.flac"), v)
+		_, ok := got[1].Get("duration")
+		assert.False(t, ok, "unknown duration of the last track")
+
+		for _, name := range []string{"album.cue", "album.log", "album.jpg"} {
+			assert.Nil(t, expand(name), name)
+		}
+	})
+}
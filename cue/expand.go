@@ -0,0 +1,130 @@
+package cue
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+)
+
+var (
+	_ info.Expander = &Expander{}
+)
+
+func NewExpander() *Expander {
+	return &Expander{}
+}
+
+// Expander expands a media file backed by a CUE sheet into virtual track records.
+//
+// The CUE sheet is read from the cuesheet tag (embedded) or the sidecar file
+// (basepath.cue or path.cue).
+// The sidecar sheet expands only the file it describes, not e.g. album.log next to album.cue.
+// The duration of the last track is omitted if the duration of the file is unknown.
+type Expander struct{}
+
+// TrackPath returns the identifier of the track, path#track=N.
+func TrackPath(path string, track int) string {
+	return fmt.Sprintf("%s#track=%d", path, track)
+}
+
+func (e Expander) Expand(_ context.Context, data *info.Metadata) ([]*info.Metadata, error) {
+	path, ok := data.Get("path")
+	if !ok {
+		return nil, nil
+	}
+	sheet, embedded, err := e.readSheet(data)
+	if err != nil || sheet == nil {
+		return nil, err
+	}
+	var file *File
+	if embedded && len(sheet.Files) == 1 {
+		// the embedded sheet describes the file itself
+		file = sheet.Files[0]
+	} else if file, ok = sheet.FindFile(filepath.Base(path)); !ok {
+		return nil, nil
+	}
+	if len(file.Tracks) == 0 {
+		return nil, nil
+	}
+
+	var total time.Duration
+	if x, ok := data.Get("duration"); ok {
+		total, _ = meta.ParseSeconds(x)
+	}
+
+	r := make([]*info.Metadata, len(file.Tracks))
+	for i, t := range file.Tracks {
+		d := map[string]string{
+			"path":        TrackPath(path, t.Number),
+			"source_path": path,
+			"track":       fmt.Sprint(t.Number),
+			"start":       formatSeconds(t.Start),
+		}
+		if t.Title != "" {
+			d["title"] = t.Title
+		}
+		if performer := cmp.Or(t.Performer, sheet.Performer); performer != "" {
+			d["performer"] = performer
+		}
+		if sheet.Title != "" {
+			d["album"] = sheet.Title
+		}
+
+		var end time.Duration
+		if i+1 < len(file.Tracks) {
+			end = file.Tracks[i+1].Start
+		} else {
+			end = total
+		}
+		if end > t.Start {
+			d["duration"] = formatSeconds(end - t.Start)
+		}
+
+		r[i] = data.Merge(meta.NewData(d))
+	}
+	return r, nil
+}
+
+// readSheet returns the sheet and true if it is embedded.
+func (Expander) readSheet(data *info.Metadata) (*Sheet, bool, error) {
+	for _, k := range []string{"cuesheet", "CUESHEET", "Cuesheet"} {
+		if x, ok := data.Get(k); ok && x != "" {
+			s, err := Parse(strings.NewReader(x))
+			return s, true, err
+		}
+	}
+
+	path, _ := data.Get("path")
+	if !IsAudio(path) {
+		// e.g. the sheet itself
+		return nil, false, nil
+	}
+	for _, c := range []string{
+		strings.TrimSuffix(path, filepath.Ext(path)) + ".cue",
+		path + ".cue",
+	} {
+		f, err := os.Open(c)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		defer f.Close()
+		s, err := Parse(f)
+		return s, false, err
+	}
+	return nil, false, nil
+}
+
+// formatSeconds formats duration like ffprobe.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
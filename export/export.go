@@ -0,0 +1,184 @@
+// Package export transcodes files into a destination directory.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/meta"
+)
+
+var (
+	ErrExport = errors.New("Export")
+)
+
+// Result is the result of the export of a file.
+type Result struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest,omitempty"`
+	// Skip is the reason why the file is skipped.
+	Skip string `json:"skip,omitempty"`
+	// DryRun is true if the file would be transcoded.
+	DryRun bool   `json:"dry_run,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Elapsed is the duration of the transcoding in seconds.
+	Elapsed float64 `json:"elapsed,omitempty"`
+}
+
+// Exporter transcodes the files with the preset.
+//
+// The destination path is rendered by the template, or mirrors the path relative to the base directories if the template is nil.
+// The extension of the destination is replaced with the extension of the preset.
+type Exporter struct {
+	transcoder meta.Transcoder
+	preset     meta.Preset
+	dest       string
+	tmpl       *layout.Template
+	bases      []string
+	dryRun     bool
+
+	mux     sync.Mutex
+	planned map[string]bool
+}
+
+func New(
+	transcoder meta.Transcoder,
+	preset meta.Preset,
+	dest string,
+	tmpl *layout.Template,
+	bases []string,
+	dryRun bool,
+) (*Exporter, error) {
+	if err := preset.Validate(); err != nil {
+		return nil, errors.Join(ErrExport, err)
+	}
+	if tmpl == nil && len(bases) == 0 {
+		return nil, fmt.Errorf("%w: neither template nor base", ErrExport)
+	}
+	return &Exporter{
+		transcoder: transcoder,
+		preset:     preset,
+		dest:       dest,
+		tmpl:       tmpl,
+		bases:      bases,
+		dryRun:     dryRun,
+		planned:    map[string]bool{},
+	}, nil
+}
+
+// Dest returns the destination path of the file.
+func (e *Exporter) Dest(data info.Getter) (string, error) {
+	var (
+		rel string
+		err error
+	)
+	if e.tmpl != nil {
+		rel, err = e.tmpl.Render(data)
+	} else {
+		path, _ := data.Get("path")
+		rel, err = layout.Rel(e.bases, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + e.preset.Ext
+	return filepath.Join(e.dest, rel), nil
+}
+
+// claim returns false if the destination is already used by another file.
+func (e *Exporter) claim(dest string) bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.planned[dest] {
+		return false
+	}
+	e.planned[dest] = true
+	return true
+}
+
+// Export transcodes the file unless the destination is up to date.
+func (e *Exporter) Export(ctx context.Context, data info.Getter) *Result {
+	path, _ := data.Get("path")
+	r := &Result{
+		Source: path,
+	}
+	dest, err := e.Dest(data)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Dest = dest
+
+	srcStat, err := os.Stat(path)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if destStat, err := os.Stat(dest); err == nil {
+		if os.SameFile(srcStat, destStat) {
+			r.Skip = "same path"
+			return r
+		}
+		if !destStat.ModTime().Before(srcStat.ModTime()) {
+			r.Skip = "up to date"
+			return r
+		}
+	}
+	if !e.claim(dest) {
+		r.Skip = "duplicated"
+		return r
+	}
+	if e.dryRun {
+		r.DryRun = true
+		return r
+	}
+
+	startTime := time.Now()
+	if err := e.transcode(ctx, path, dest, srcStat.Mode().Perm()); err != nil {
+		r.Error = err.Error()
+		if stderr := meta.Stderr(err); stderr != "" {
+			r.Error += ": " + strings.TrimSpace(stderr)
+		}
+		return r
+	}
+	r.Elapsed = time.Since(startTime).Seconds()
+	return r
+}
+
+// transcode writes a temporary file and renames it to keep the destination complete.
+// The destination has the permissions of the source, not 0600 of the temporary file.
+func (e *Exporter) transcode(ctx context.Context, path, dest string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	// ffmpeg guesses the format from the extension
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".fflist-export-*"+e.preset.Ext)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+
+	if err := e.transcoder.Transcode(ctx, path, tmpPath, e.preset); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package export_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/export"
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+// copyTranscoder copies the input with the prefix of the preset.
+type copyTranscoder struct {
+	calls []string
+}
+
+func (t *copyTranscoder) Transcode(_ context.Context, input, output string, preset meta.Preset) error {
+	t.calls = append(t.calls, input)
+	b, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	if string(b) == "broken" {
+		return errors.New("broken")
+	}
+	return os.WriteFile(output, append([]byte(preset.Args[0]+":"), b...), 0600)
+}
+
+func TestExport(t *testing.T) {
+	preset := meta.Preset{
+		Ext:  ".mp3",
+		Args: []string{"mp3"},
+	}
+
+	setup := func(t *testing.T) (string, string, map[string]info.Getter) {
+		var (
+			src  = t.TempDir()
+			dest = t.TempDir()
+			r    = map[string]info.Getter{}
+		)
+		for name, tags := range map[string]map[string]string{
+			"A/x.flac":      {"artist": "X", "title": "T1"},
+			"B/sub/y.flac":  {"artist": "Y", "title": "T2"},
+			"B/broken.flac": {"artist": "Y", "title": "T3"},
+		} {
+			path := filepath.Join(src, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			content := name
+			if filepath.Base(name) == "broken.flac" {
+				content = "broken"
+			}
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			tags["path"] = path
+			r[name] = info.New(meta.NewData(tags))
+		}
+		return src, dest, r
+	}
+	read := func(t *testing.T, path string) string {
+		b, err := os.ReadFile(path)
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		return string(b)
+	}
+
+	t.Run("mirror", func(t *testing.T) {
+		src, dest, data := setup(t)
+		tc := &copyTranscoder{}
+		e, err := export.New(tc, preset, dest, nil, []string{src}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		r := e.Export(context.TODO(), data["B/sub/y.flac"])
+		assert.Equal(t, "", r.Error)
+		assert.Equal(t, "", r.Skip)
+		assert.Equal(t, filepath.Join(dest, "B/sub/y.mp3"), r.Dest)
+		assert.Equal(t, "mp3:B/sub/y.flac", read(t, r.Dest))
+
+		r = e.Export(context.TODO(), data["B/broken.flac"])
+		assert.Contains(t, r.Error, "broken")
+		_, err = os.Stat(filepath.Join(dest, "B/broken.mp3"))
+		assert.True(t, os.IsNotExist(err))
+		entries, err := os.ReadDir(filepath.Join(dest, "B"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, 1, len(entries), "no temporary files")
+		}
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		src, dest, data := setup(t)
+		if !assert.Nil(t, os.Chmod(filepath.Join(src, "A/x.flac"), 0644)) {
+			return
+		}
+		e, err := export.New(&copyTranscoder{}, preset, dest, nil, []string{src}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r := e.Export(context.TODO(), data["A/x.flac"])
+		if !assert.Equal(t, "", r.Error) {
+			return
+		}
+		stat, err := os.Stat(r.Dest)
+		if assert.Nil(t, err) {
+			assert.Equal(t, os.FileMode(0644), stat.Mode().Perm())
+		}
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		src, dest, data := setup(t)
+		tc := &copyTranscoder{}
+		e, err := export.New(tc, preset, dest, nil, []string{src}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r := e.Export(context.TODO(), data["A/x.flac"])
+		if !assert.Equal(t, "", r.Error) {
+			return
+		}
+
+		e, err = export.New(tc, preset, dest, nil, []string{src}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r = e.Export(context.TODO(), data["A/x.flac"])
+		assert.Equal(t, "up to date", r.Skip)
+
+		future := time.Now().Add(time.Hour)
+		if !assert.Nil(t, os.Chtimes(filepath.Join(src, "A/x.flac"), future, future)) {
+			return
+		}
+		r = e.Export(context.TODO(), data["A/x.flac"])
+		assert.Equal(t, "", r.Skip)
+		assert.Equal(t, 2, len(tc.calls))
+	})
+
+	t.Run("template", func(t *testing.T) {
+		_, dest, data := setup(t)
+		tmpl, err := layout.New(`{{.artist}}/{{.title}}.flac`)
+		if !assert.Nil(t, err) {
+			return
+		}
+		e, err := export.New(&copyTranscoder{}, preset, dest, tmpl, nil, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r := e.Export(context.TODO(), data["A/x.flac"])
+		assert.Equal(t, "", r.Error)
+		assert.Equal(t, "mp3:A/x.flac", read(t, filepath.Join(dest, "X/T1.mp3")))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		src, dest, data := setup(t)
+		tc := &copyTranscoder{}
+		e, err := export.New(tc, preset, dest, nil, []string{src}, true)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r := e.Export(context.TODO(), data["A/x.flac"])
+		assert.Equal(t, filepath.Join(dest, "A/x.mp3"), r.Dest)
+		assert.True(t, r.DryRun)
+		assert.Equal(t, 0, len(tc.calls))
+		_, err = os.Stat(r.Dest)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("not under base", func(t *testing.T) {
+		_, dest, data := setup(t)
+		e, err := export.New(&copyTranscoder{}, preset, dest, nil, []string{t.TempDir()}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		r := e.Export(context.TODO(), data["A/x.flac"])
+		assert.Contains(t, r.Error, "not under the base")
+	})
+
+	t.Run("invalid preset", func(t *testing.T) {
+		_, err := export.New(&copyTranscoder{}, meta.Preset{Args: []string{"x"}}, "dest", nil, []string{"."}, false)
+		assert.ErrorIs(t, err, export.ErrExport)
+	})
+
+	t.Run("preset ext without dot", func(t *testing.T) {
+		_, err := export.New(&copyTranscoder{}, meta.Preset{Ext: "mp3", Args: []string{"x"}}, "dest", nil, []string{"."}, false)
+		assert.ErrorIs(t, err, export.ErrExport)
+	})
+}
@@ -5,10 +5,12 @@ go 1.23.3
 require (
 	github.com/berquerant/dataclass v0.4.0
 	github.com/berquerant/execx v0.6.2
+	github.com/expr-lang/expr v1.16.9
 	github.com/go-task/task/v3 v3.40.1
 	github.com/spf13/cobra v1.8.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/sync v0.10.0
+	golang.org/x/sys v0.30.0
 	golang.org/x/vuln v1.1.4
 	gopkg.in/yaml.v3 v3.0.1
 	gotest.tools/gotestsum v1.12.1
@@ -63,7 +65,6 @@ require (
 	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/mod v0.22.0 // indirect
 	golang.org/x/net v0.34.0 // indirect
-	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/telemetry v0.0.0-20240522233618-39ace7a40ae7 // indirect
 	golang.org/x/term v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
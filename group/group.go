@@ -0,0 +1,236 @@
+// Package group assembles files into albums and aggregates them.
+package group
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+)
+
+var (
+	ErrGroup = errors.New("Group")
+)
+
+// By is the key to group files.
+type By string
+
+const (
+	// ByDir groups files by the directory.
+	ByDir By = "dir"
+	// ByAlbum groups files by album and album_artist.
+	ByAlbum By = "album"
+)
+
+func ParseBy(s string) (By, error) {
+	switch x := By(s); x {
+	case ByDir, ByAlbum:
+		return x, nil
+	default:
+		return "", fmt.Errorf("%w: unknown group %s", ErrGroup, s)
+	}
+}
+
+// Aggregate keys.
+const (
+	// KeyKey is the identifier of the group, the directory or album_artist/album.
+	KeyKey = "album.key"
+	// KeyTrackCount is the number of files in the group.
+	KeyTrackCount = "album.track_count"
+	// KeyTotalDuration is the sum of the duration of the files in seconds.
+	KeyTotalDuration = "album.total_duration"
+	// KeyMissingTracks is the missing track numbers per disc, as meta.MultiValue.
+	// The track numbers are prefixed with the disc number if the group has several discs, e.g. 2-3.
+	KeyMissingTracks = "album.missing_tracks"
+	// KeyFormats is the extensions of the files, as meta.MultiValue.
+	KeyFormats = "album.formats"
+	// KeyMixedFormats is true if the group has several extensions.
+	KeyMixedFormats = "album.mixed_formats"
+)
+
+// get returns the value of the first existing key, tags may be in upper case, e.g. ALBUM of flac.
+func get(data info.Getter, keys ...string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := data.Get(k); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// KeyOf returns the identifier of the group of the data.
+// Returns false if the data does not belong to any group, e.g. no album.
+func KeyOf(by By, data info.Getter) (string, bool) {
+	switch by {
+	case ByDir:
+		return data.Get("dir")
+	case ByAlbum:
+		album, ok := get(data, "album", "ALBUM", "Album")
+		if !ok || album == "" {
+			return "", false
+		}
+		artist, _ := get(data, "album_artist", "ALBUM_ARTIST", "Album_Artist", "albumartist", "ALBUMARTIST")
+		return artist + "/" + album, true
+	default:
+		return "", false
+	}
+}
+
+// Grouper assembles files into groups.
+type Grouper struct {
+	by     By
+	keys   []string
+	groups map[string][]info.Getter
+	// files not in any group
+	rest []info.Getter
+}
+
+func New(by By) *Grouper {
+	return &Grouper{
+		by:     by,
+		groups: map[string][]info.Getter{},
+	}
+}
+
+func (g *Grouper) Add(data info.Getter) {
+	key, ok := KeyOf(g.by, data)
+	if !ok {
+		g.rest = append(g.rest, data)
+		return
+	}
+	if _, ok := g.groups[key]; !ok {
+		g.keys = append(g.keys, key)
+	}
+	g.groups[key] = append(g.groups[key], data)
+}
+
+// Files returns the files with the aggregate keys of their groups.
+// The files not in any group are returned as they are.
+func (g *Grouper) Files() []info.Getter {
+	r := make([]info.Getter, 0, len(g.rest))
+	for _, key := range g.keys {
+		agg := meta.NewData(aggregate(key, g.groups[key]))
+		for _, x := range g.groups[key] {
+			r = append(r, merge(x, agg))
+		}
+	}
+	return append(r, g.rest...)
+}
+
+// Records returns a record per group with the aggregate keys.
+// The path of the record is the identifier of the group.
+func (g *Grouper) Records() []info.Getter {
+	r := make([]info.Getter, len(g.keys))
+	for i, key := range g.keys {
+		d := aggregate(key, g.groups[key])
+		d["path"] = key
+		if g.by == ByAlbum {
+			first := g.groups[key][0]
+			d["album"], _ = get(first, "album", "ALBUM", "Album")
+			d["album_artist"], _ = get(first, "album_artist", "ALBUM_ARTIST", "Album_Artist", "albumartist", "ALBUMARTIST")
+		}
+		r[i] = info.New(meta.NewData(d))
+	}
+	return r
+}
+
+func merge(data info.Getter, agg *meta.Data) info.Getter {
+	if x, ok := data.(*info.Metadata); ok {
+		return x.Merge(agg)
+	}
+	m, err := info.ToMap(data)
+	if err != nil {
+		return data
+	}
+	return info.New(meta.NewData(m), agg)
+}
+
+func aggregate(key string, files []info.Getter) map[string]string {
+	var (
+		totalDuration float64
+		formats       = map[string]bool{}
+		// disc number, 0 if unknown -> tracks
+		discs = map[int]*discTracks{}
+	)
+	for _, x := range files {
+		if v, ok := x.Get("duration"); ok {
+			if d, err := strconv.ParseFloat(v, 64); err == nil {
+				totalDuration += d
+			}
+		}
+		if v, ok := x.Get("ext"); ok && v != "" {
+			formats[strings.ToLower(v)] = true
+		} else if v, ok := x.Get("path"); ok {
+			formats[strings.ToLower(filepath.Ext(v))] = true
+		}
+		if v, ok := get(x, "track", "TRACK", "Track", "TRACKNUMBER", "tracknumber"); ok {
+			var disc int
+			if v, ok := get(x, "disc", "DISC", "Disc", "DISCNUMBER", "discnumber"); ok {
+				disc, _ = parseTrack(v)
+			}
+			if _, ok := discs[disc]; !ok {
+				discs[disc] = &discTracks{
+					tracks: map[int]bool{},
+				}
+			}
+			discs[disc].add(parseTrack(v))
+		}
+	}
+
+	var missing []string
+	for _, disc := range slices.Sorted(maps.Keys(discs)) {
+		for _, n := range discs[disc].missing() {
+			if len(discs) > 1 {
+				missing = append(missing, fmt.Sprintf("%d-%d", disc, n))
+				continue
+			}
+			missing = append(missing, strconv.Itoa(n))
+		}
+	}
+	return map[string]string{
+		KeyKey:           key,
+		KeyTrackCount:    strconv.Itoa(len(files)),
+		KeyTotalDuration: strconv.FormatFloat(totalDuration, 'f', 6, 64),
+		KeyMissingTracks: meta.MultiValue(missing),
+		KeyFormats:       meta.MultiValue(slices.Sorted(maps.Keys(formats))),
+		KeyMixedFormats:  strconv.FormatBool(len(formats) > 1),
+	}
+}
+
+// discTracks is the track numbers of a disc.
+type discTracks struct {
+	tracks   map[int]bool
+	maxTrack int
+}
+
+func (d *discTracks) add(n, total int) {
+	if n > 0 {
+		d.tracks[n] = true
+		d.maxTrack = max(d.maxTrack, n)
+	}
+	d.maxTrack = max(d.maxTrack, total)
+}
+
+func (d *discTracks) missing() []int {
+	var r []int
+	for i := 1; len(d.tracks) > 0 && i <= d.maxTrack; i++ {
+		if !d.tracks[i] {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// parseTrack parses N or N/M.
+func parseTrack(s string) (int, int) {
+	n, total, _ := strings.Cut(strings.TrimSpace(s), "/")
+	x, _ := strconv.Atoi(strings.TrimSpace(n))
+	y, _ := strconv.Atoi(strings.TrimSpace(total))
+	return x, y
+}
@@ -0,0 +1,145 @@
+package group_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/fflist/group"
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrouper(t *testing.T) {
+	files := []map[string]string{
+		{"path": "/m/a/1.flac", "dir": "/m/a", "ext": ".flac", "ALBUM": "A", "ALBUM_ARTIST": "X", "TRACK": "1/4", "duration": "60.5"},
+		{"path": "/m/a/3.mp3", "dir": "/m/a", "ext": ".mp3", "album": "A", "album_artist": "X", "track": "3", "duration": "30"},
+		{"path": "/m/b/1.mp3", "dir": "/m/b", "ext": ".mp3", "album": "B", "track": "1", "duration": "10"},
+		{"path": "/m/b/2.mp3", "dir": "/m/b", "ext": ".mp3", "album": "B", "track": "2", "duration": "10"},
+		{"path": "/m/c/readme.txt", "dir": "/m/c", "ext": ".txt"},
+	}
+	newGrouper := func(by group.By) *group.Grouper {
+		g := group.New(by)
+		for _, f := range files {
+			g.Add(info.New(meta.NewData(f)))
+		}
+		return g
+	}
+	toMaps := func(t *testing.T, xs []info.Getter) []map[string]string {
+		r := make([]map[string]string, len(xs))
+		for i, x := range xs {
+			m, err := info.ToMap(x)
+			if !assert.Nil(t, err) {
+				t.FailNow()
+			}
+			r[i] = m
+		}
+		return r
+	}
+
+	t.Run("album records", func(t *testing.T) {
+		got := toMaps(t, newGrouper(group.ByAlbum).Records())
+		assert.Equal(t, []map[string]string{
+			{
+				"path":                 "X/A",
+				"album":                "A",
+				"album_artist":         "X",
+				"album.key":            "X/A",
+				"album.track_count":    "2",
+				"album.total_duration": "90.500000",
+				"album.missing_tracks": `["2","4"]`,
+				"album.formats":        `[".flac",".mp3"]`,
+				"album.mixed_formats":  "true",
+			},
+			{
+				"path":                 "/B",
+				"album":                "B",
+				"album_artist":         "",
+				"album.key":            "/B",
+				"album.track_count":    "2",
+				"album.total_duration": "20.000000",
+				"album.missing_tracks": "",
+				"album.formats":        `[".mp3"]`,
+				"album.mixed_formats":  "false",
+			},
+		}, got)
+	})
+
+	t.Run("dir files", func(t *testing.T) {
+		got := toMaps(t, newGrouper(group.ByDir).Files())
+		if !assert.Equal(t, len(files), len(got)) {
+			return
+		}
+		for i, want := range []map[string]string{
+			{"path": "/m/a/1.flac", "album.key": "/m/a", "album.mixed_formats": "true"},
+			{"path": "/m/a/3.mp3", "album.key": "/m/a", "album.mixed_formats": "true"},
+			{"path": "/m/b/1.mp3", "album.key": "/m/b", "album.mixed_formats": "false"},
+			{"path": "/m/b/2.mp3", "album.key": "/m/b", "album.mixed_formats": "false"},
+			{"path": "/m/c/readme.txt", "album.key": "/m/c", "album.mixed_formats": "false"},
+		} {
+			for k, v := range want {
+				assert.Equal(t, v, got[i][k], "%d %s", i, k)
+			}
+		}
+	})
+
+	t.Run("album files", func(t *testing.T) {
+		got := toMaps(t, newGrouper(group.ByAlbum).Files())
+		if !assert.Equal(t, len(files), len(got)) {
+			return
+		}
+		// not in any album
+		last := got[len(got)-1]
+		assert.Equal(t, "/m/c/readme.txt", last["path"])
+		_, ok := last["album.key"]
+		assert.False(t, ok)
+	})
+}
+
+func TestGrouperMissingTracksPerDisc(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		files []map[string]string
+		want  string
+	}{
+		{
+			title: "single disc",
+			files: []map[string]string{
+				{"path": "1.mp3", "album": "A", "disc": "1/1", "track": "1/3"},
+				{"path": "3.mp3", "album": "A", "disc": "1/1", "track": "3/3"},
+			},
+			want: `["2"]`,
+		},
+		{
+			title: "discs",
+			files: []map[string]string{
+				{"path": "1-1.flac", "album": "A", "DISCNUMBER": "1", "TRACKNUMBER": "1"},
+				{"path": "1-2.flac", "album": "A", "DISCNUMBER": "1", "TRACKNUMBER": "2"},
+				{"path": "2-1.flac", "album": "A", "DISCNUMBER": "2", "TRACKNUMBER": "1"},
+				{"path": "2-3.flac", "album": "A", "DISCNUMBER": "2", "TRACKNUMBER": "3"},
+			},
+			want: `["2-2"]`,
+		},
+		{
+			title: "no gaps in discs",
+			files: []map[string]string{
+				{"path": "1-1.mp3", "album": "A", "disc": "1/2", "track": "1/1"},
+				{"path": "2-1.mp3", "album": "A", "disc": "2/2", "track": "1/2"},
+				{"path": "2-2.mp3", "album": "A", "disc": "2/2", "track": "2/2"},
+			},
+			want: "",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			g := group.New(group.ByAlbum)
+			for _, f := range tc.files {
+				g.Add(info.New(meta.NewData(f)))
+			}
+			records := g.Records()
+			if !assert.Equal(t, 1, len(records)) {
+				return
+			}
+			got, _ := records[0].Get(group.KeyMissingTracks)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package info
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/berquerant/fflist/meta"
+)
+
+var (
+	_ Expander = &ChapterExpander{}
+)
+
+func NewChapterExpander() *ChapterExpander {
+	return &ChapterExpander{}
+}
+
+// ChapterExpander expands a media file into the records per chapter.
+// The chapters are read from the keys of ffprobe -show_chapters, e.g. chapters.0.tags.title.
+type ChapterExpander struct{}
+
+func (ChapterExpander) Expand(_ context.Context, data *Metadata) ([]*Metadata, error) {
+	x, ok := data.Get("chapter_count")
+	if !ok {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(x)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	r := make([]*Metadata, count)
+	for i := range count {
+		get := func(key string) string {
+			v, _ := data.Get(fmt.Sprintf("chapters.%d.%s", i, key))
+			return v
+		}
+		r[i] = data.Merge(meta.NewData(map[string]string{
+			"chapter.index": fmt.Sprint(i),
+			"chapter.title": get("tags.title"),
+			"chapter.start": get("start_time"),
+			"chapter.end":   get("end_time"),
+		}))
+	}
+	return r, nil
+}
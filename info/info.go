@@ -1,8 +1,13 @@
 package info
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"iter"
+	"maps"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -46,21 +51,63 @@ func (d Metadata) Get(key string) (string, bool) {
 	return d.data.Get(key)
 }
 
+// All returns all the keys and values.
+func (d Metadata) All() iter.Seq2[string, string] {
+	return d.data.All()
+}
+
+// Merge returns a new metadata overwritten by dataList.
+func (d Metadata) Merge(dataList ...*meta.Data) *Metadata {
+	return New(append([]*meta.Data{d.data}, dataList...)...)
+}
+
+// Expander expands a metadata into multiple metadata, e.g. tracks of a single file album.
+type Expander interface {
+	// Expand returns nil if data is not expandable.
+	Expand(ctx context.Context, data *Metadata) ([]*Metadata, error)
+}
+
 func NewMetadataFromEntry(entry walk.Entry) *meta.Data {
 	var (
-		path = entry.Path()
-		name = entry.Info().Name()
-		ext  = filepath.Ext(name)
+		path      = entry.Path()
+		name      = entry.Info().Name()
+		ext       = filepath.Ext(name)
+		modTime   = entry.Info().ModTime()
+		isSymlink = entry.Info().Mode()&fs.ModeSymlink != 0
 	)
-	return meta.NewData(map[string]string{
-		"path":     path,
-		"dir":      filepath.Dir(path),
-		"name":     name,
-		"ext":      ext,
-		"basename": strings.TrimRight(name, ext),
-		"basepath": strings.TrimRight(path, ext),
-		"size":     fmt.Sprint(entry.Info().Size()),
-		"mode":     fmt.Sprintf("%o", entry.Info().Mode()),
-		"mod_time": entry.Info().ModTime().Format(time.DateTime),
-	})
+	d := map[string]string{
+		"path":             path,
+		"dir":              filepath.Dir(path),
+		"name":             name,
+		"ext":              ext,
+		"basename":         strings.TrimRight(name, ext),
+		"basepath":         strings.TrimRight(path, ext),
+		"size":             fmt.Sprint(entry.Info().Size()),
+		"mode":             fmt.Sprintf("%o", entry.Info().Mode()),
+		"mod_time":         modTime.Format(time.DateTime),
+		"mod_time_rfc3339": modTime.Format(time.RFC3339),
+		"mod_time_unix":    fmt.Sprint(modTime.Unix()),
+		"is_symlink":       fmt.Sprint(isSymlink),
+	}
+	// the path of the virtual entry cannot be read from the OS
+	if isSymlink && !walk.IsVirtual(entry) {
+		if target, err := os.Readlink(path); err == nil {
+			d["link_target"] = target
+		}
+	}
+	maps.Copy(d, statData(entry))
+	return meta.NewData(d)
+}
+
+// ToMap returns all the keys and values of the data.
+func ToMap(data Getter) (map[string]string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var r map[string]string
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
@@ -0,0 +1,73 @@
+package info_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/walk"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEntry(t *testing.T, path string) walk.Entry {
+	t.Helper()
+	stat, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return walk.NewEntry(path, stat)
+}
+
+func TestNewMetadataFromEntry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.mp3")
+	if err := os.Symlink("a.mp3", link); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("file", func(t *testing.T) {
+		d := info.NewMetadataFromEntry(newEntry(t, path))
+		for k, want := range map[string]string{
+			"path":       path,
+			"dir":        dir,
+			"name":       "a.mp3",
+			"ext":        ".mp3",
+			"size":       "3",
+			"is_symlink": "false",
+		} {
+			got, _ := d.Get(k)
+			assert.Equal(t, want, got, k)
+		}
+		_, ok := d.Get("link_target")
+		assert.False(t, ok)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		d := info.NewMetadataFromEntry(newEntry(t, link))
+		got, _ := d.Get("is_symlink")
+		assert.Equal(t, "true", got)
+		got, _ = d.Get("link_target")
+		assert.Equal(t, "a.mp3", got)
+	})
+
+	t.Run("fs", func(t *testing.T) {
+		// the path is relative to the fs.FS, not to the working directory
+		fsys := os.DirFS(dir)
+		stat, err := os.Lstat(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := walk.NewFSEntry(walk.NewEntry("link.mp3", stat), fsys)
+		assert.True(t, walk.IsVirtual(entry))
+		d := info.NewMetadataFromEntry(entry)
+		_, ok := d.Get("link_target")
+		assert.False(t, ok)
+		_, ok = d.Get("birth_time")
+		assert.False(t, ok)
+	})
+}
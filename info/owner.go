@@ -0,0 +1,43 @@
+package info
+
+import (
+	"fmt"
+	"os/user"
+	"sync"
+)
+
+var (
+	ownerCache sync.Map // uid -> name
+	groupCache sync.Map // gid -> name
+)
+
+func lookupOwner(uid uint32) (string, bool) {
+	return lookupCached(&ownerCache, uid, func(id string) (string, error) {
+		u, err := user.LookupId(id)
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	})
+}
+
+func lookupGroup(gid uint32) (string, bool) {
+	return lookupCached(&groupCache, gid, func(id string) (string, error) {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	})
+}
+
+func lookupCached(cache *sync.Map, id uint32, lookup func(string) (string, error)) (string, bool) {
+	if x, ok := cache.Load(id); ok {
+		name := x.(string)
+		return name, name != ""
+	}
+	// cache the failure as empty name
+	name, _ := lookup(fmt.Sprint(id))
+	cache.Store(id, name)
+	return name, name != ""
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package info
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/berquerant/fflist/walk"
+	"golang.org/x/sys/unix"
+)
+
+// statData returns the metadata from syscall.Stat_t.
+func statData(entry walk.Entry) map[string]string {
+	st, ok := entry.Info().Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	r := map[string]string{
+		"atime": formatTimespec(st.Atim),
+		"ctime": formatTimespec(st.Ctim),
+		"inode": fmt.Sprint(st.Ino),
+		"dev":   fmt.Sprint(st.Dev),
+		"nlink": fmt.Sprint(st.Nlink),
+		"uid":   fmt.Sprint(st.Uid),
+		"gid":   fmt.Sprint(st.Gid),
+	}
+	if x, ok := lookupOwner(st.Uid); ok {
+		r["owner"] = x
+	}
+	if x, ok := lookupGroup(st.Gid); ok {
+		r["group"] = x
+	}
+	if x, ok := birthTime(entry); ok {
+		r["birth_time"] = x.Format(time.RFC3339)
+	}
+	return r
+}
+
+func formatTimespec(ts syscall.Timespec) string {
+	return time.Unix(ts.Unix()).Format(time.RFC3339)
+}
+
+// birthTime returns the creation time of the file if the filesystem supports it.
+func birthTime(entry walk.Entry) (time.Time, bool) {
+	if walk.IsVirtual(entry) {
+		return time.Time{}, false
+	}
+	flags := 0
+	if entry.Info().Mode()&fs.ModeSymlink != 0 {
+		flags |= unix.AT_SYMLINK_NOFOLLOW
+	}
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, entry.Path(), flags, unix.STATX_BTIME, &stx); err != nil {
+		return time.Time{}, false
+	}
+	if stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
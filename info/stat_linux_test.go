@@ -0,0 +1,47 @@
+//go:build linux
+
+package info_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(path, filepath.Join(dir, "b.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	entry := newEntry(t, path)
+	st := entry.Info().Sys().(*syscall.Stat_t)
+
+	d := info.NewMetadataFromEntry(entry)
+	for k, want := range map[string]string{
+		"nlink": "2",
+		"inode": fmt.Sprint(st.Ino),
+		"dev":   fmt.Sprint(st.Dev),
+		"uid":   fmt.Sprint(os.Getuid()),
+		"gid":   fmt.Sprint(os.Getgid()),
+	} {
+		got, _ := d.Get(k)
+		assert.Equal(t, want, got, k)
+	}
+	for _, k := range []string{"atime", "ctime"} {
+		got, ok := d.Get(k)
+		assert.True(t, ok && got != "", k)
+	}
+	// birth_time depends on the filesystem
+	if got, ok := d.Get("birth_time"); ok {
+		assert.NotEqual(t, "", got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package info
+
+import "github.com/berquerant/fflist/walk"
+
+// statData returns nothing because the extended stat is available only on linux.
+func statData(_ walk.Entry) map[string]string {
+	return nil
+}
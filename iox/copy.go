@@ -0,0 +1,52 @@
+package iox
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/berquerant/fflist/logx"
+)
+
+// CopyFile copies the file through a temporary file, keeping the mode and the modification time.
+//
+// Failure to change the mode is ignored, because some filesystems, e.g. vfat and exFAT, do not support it.
+func CopyFile(src, dest string) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dest), ".fflist-copy-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := out.Name()
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, stat.Mode().Perm()); err != nil {
+		slog.Debug("Failed to keep the mode", slog.String("path", dest), logx.Err(err))
+	}
+	if err := os.Chtimes(tmpPath, stat.ModTime(), stat.ModTime()); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
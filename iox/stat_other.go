@@ -0,0 +1,15 @@
+//go:build !unix
+
+package iox
+
+import "io/fs"
+
+// Nlink returns the number of the hard links of the file.
+func Nlink(_ fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// CopyOwner changes the owner of the file to the owner of info if they differ.
+func CopyOwner(_ string, _ fs.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package iox
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// Nlink returns the number of the hard links of the file.
+func Nlink(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Nlink), true
+}
+
+// CopyOwner changes the owner of the file to the owner of info if they differ.
+func CopyOwner(path string, info fs.FileInfo) error {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if got, ok := stat.Sys().(*syscall.Stat_t); ok && got.Uid == want.Uid && got.Gid == want.Gid {
+		return nil
+	}
+	return os.Chown(path, int(want.Uid), int(want.Gid))
+}
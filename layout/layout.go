@@ -0,0 +1,143 @@
+// Package layout renders file paths from metadata.
+package layout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/berquerant/fflist/info"
+)
+
+var (
+	ErrLayout = errors.New("Layout")
+)
+
+// Template renders a relative file path from metadata by text/template.
+//
+// The keys of the metadata are available as {{.key}} and the missing keys are empty.
+// The values are sanitized so that they do not contain path separators.
+// The following functions are available in addition to the builtin functions:
+//
+//   - get KEY: the value of KEY, for the keys that are not valid identifiers, e.g. {{get "album_artist"}}
+//   - default DEFAULT VALUE: DEFAULT if VALUE is empty
+//   - num VALUE: the leading number of VALUE, e.g. 3 of 3/12
+type Template struct {
+	tmpl *template.Template
+}
+
+func New(text string) (*Template, error) {
+	tmpl, err := template.New("layout").
+		Option("missingkey=zero").
+		Funcs(template.FuncMap{
+			// replaced by Render
+			"get":     func(string) string { return "" },
+			"default": defaultValue,
+			"num":     leadingNumber,
+		}).
+		Parse(text)
+	if err != nil {
+		return nil, errors.Join(ErrLayout, err)
+	}
+	return &Template{
+		tmpl: tmpl,
+	}, nil
+}
+
+func defaultValue(d, v string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
+var leadingNumberRegexp = regexp.MustCompile(`^\s*(\d+)`)
+
+func leadingNumber(v string) string {
+	if m := leadingNumberRegexp.FindStringSubmatch(v); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Render returns the relative path.
+func (t *Template) Render(data info.Getter) (string, error) {
+	m, err := info.ToMap(data)
+	if err != nil {
+		return "", errors.Join(ErrLayout, err)
+	}
+	for k, v := range m {
+		m[k] = Sanitize(v)
+	}
+
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", errors.Join(ErrLayout, err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"get": func(key string) string { return m[key] },
+	})
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, m); err != nil {
+		return "", errors.Join(ErrLayout, err)
+	}
+	return cleanPath(b.String())
+}
+
+func cleanPath(p string) (string, error) {
+	elems := strings.Split(filepath.ToSlash(p), "/")
+	r := make([]string, 0, len(elems))
+	for _, x := range elems {
+		x = strings.TrimSpace(x)
+		switch x {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("%w: parent directory in %s", ErrLayout, p)
+		}
+		r = append(r, x)
+	}
+	if len(r) == 0 {
+		return "", fmt.Errorf("%w: empty path", ErrLayout)
+	}
+	return filepath.Join(r...), nil
+}
+
+var sanitizeRegexp = regexp.MustCompile(`[/\\<>:"|?*\x00-\x1f]`)
+
+// Sanitize replaces the characters that are not allowed in file names with _.
+func Sanitize(s string) string {
+	s = sanitizeRegexp.ReplaceAllString(s, "_")
+	s = strings.TrimSpace(s)
+	// trailing dots are not allowed on some filesystems
+	s = strings.TrimRight(s, ".")
+	if s == "" || s == "." || s == ".." {
+		return ""
+	}
+	return s
+}
+
+// Rel returns the path relative to the first base directory containing it.
+func Rel(bases []string, path string) (string, error) {
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Join(ErrLayout, err)
+	}
+	for _, base := range bases {
+		b, err := filepath.Abs(base)
+		if err != nil {
+			return "", errors.Join(ErrLayout, err)
+		}
+		rel, err := filepath.Rel(b, p)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return rel, nil
+	}
+	return "", fmt.Errorf("%w: %s is not under the base directories", ErrLayout, path)
+}
@@ -0,0 +1,68 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	data := info.New(meta.NewData(map[string]string{
+		"artist":       "AC/DC",
+		"album":        "Live: 1992?",
+		"album_artist": "Various",
+		"track":        "3/12",
+		"title":        " Title. ",
+		"ext":          ".mp3",
+	}))
+
+	for _, tc := range []struct {
+		title string
+		text  string
+		want  string
+		err   error
+	}{
+		{
+			title: "printf",
+			text:  `{{.artist}}/{{.album}}/{{printf "%02s" (num .track)}} {{.title}}{{.ext}}`,
+			want:  "AC_DC/Live_ 1992_/03 Title.mp3",
+		},
+		{
+			title: "get and default",
+			text:  `{{get "album_artist"}}/{{default "Unknown" .genre}}/{{.title}}{{.ext}}`,
+			want:  "Various/Unknown/Title.mp3",
+		},
+		{
+			title: "missing directory is skipped",
+			text:  `{{.genre}}/{{.title}}{{.ext}}`,
+			want:  "Title.mp3",
+		},
+		{
+			title: "parent directory",
+			text:  `../{{.title}}`,
+			err:   layout.ErrLayout,
+		},
+		{
+			title: "empty",
+			text:  `{{.genre}}`,
+			err:   layout.ErrLayout,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			tmpl, err := layout.New(tc.text)
+			if !assert.Nil(t, err) {
+				return
+			}
+			got, err := tmpl.Render(data)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
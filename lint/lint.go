@@ -0,0 +1,350 @@
+// Package lint checks the metadata against the rules.
+package lint
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+)
+
+var (
+	ErrConfig = errors.New("LintConfig")
+)
+
+// Config is the set of the rules.
+type Config struct {
+	Required   []RequiredRule   `json:"required" yaml:"required"`
+	Format     []FormatRule     `json:"format" yaml:"format"`
+	Consistent []ConsistentRule `json:"consistent" yaml:"consistent"`
+	Disallowed []DisallowedRule `json:"disallowed" yaml:"disallowed"`
+}
+
+// IsEmpty returns true if there are no rules.
+func (c Config) IsEmpty() bool {
+	return len(c.Required) == 0 && len(c.Format) == 0 && len(c.Consistent) == 0 && len(c.Disallowed) == 0
+}
+
+// RequiredRule requires the keys.
+type RequiredRule struct {
+	// Ext is the extensions of the files to which the rule applies, e.g. mp3, .flac.
+	// Empty means all files.
+	Ext  []string `json:"ext" yaml:"ext"`
+	Keys []string `json:"keys" yaml:"keys"`
+}
+
+// FormatRule requires the format of the value of the key if the key exists.
+type FormatRule struct {
+	Ext []string `json:"ext" yaml:"ext"`
+	Key string   `json:"key" yaml:"key"`
+	// Pattern is the regular expression the value should match.
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// Type is the type the value should be interpreted as: int, float, duration or date.
+	Type string `json:"type" yaml:"type"`
+}
+
+// ConsistentRule requires the same values of the keys within a directory.
+// The values are compared among the files matching the same rule.
+type ConsistentRule struct {
+	Ext  []string `json:"ext" yaml:"ext"`
+	Keys []string `json:"keys" yaml:"keys"`
+}
+
+// DisallowedRule disallows the characters in the values of the keys.
+type DisallowedRule struct {
+	Ext []string `json:"ext" yaml:"ext"`
+	// Keys are the keys to check, empty means all keys.
+	Keys  []string `json:"keys" yaml:"keys"`
+	Chars string   `json:"chars" yaml:"chars"`
+}
+
+// Rule names of Violation.
+const (
+	RuleRequired   = "required"
+	RuleFormat     = "format"
+	RuleConsistent = "consistent"
+	RuleDisallowed = "disallowed"
+)
+
+// Violation is a violation of a rule.
+type Violation struct {
+	Path  string `json:"path"`
+	Rule  string `json:"rule"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	// Expected is the expected value or format.
+	Expected string `json:"expected,omitempty"`
+}
+
+// Linter checks the metadata against the rules.
+type Linter struct {
+	required   []*requiredRule
+	format     []*formatRule
+	consistent []*consistentRule
+	disallowed []*disallowedRule
+	// value -> paths
+	dirValues map[dirValueKey]map[string][]string
+}
+
+// dirValueKey identifies the values of the key of the consistent rule within the directory.
+type dirValueKey struct {
+	dir  string
+	rule int // index of the consistent rule
+	key  string
+}
+
+func (a dirValueKey) compare(b dirValueKey) int {
+	return cmp.Or(
+		cmp.Compare(a.dir, b.dir),
+		cmp.Compare(a.rule, b.rule),
+		cmp.Compare(a.key, b.key),
+	)
+}
+
+type extMatcher []string
+
+func newExtMatcher(ext []string) extMatcher {
+	r := make([]string, len(ext))
+	for i, x := range ext {
+		r[i] = "." + strings.TrimPrefix(strings.ToLower(x), ".")
+	}
+	return r
+}
+
+func (m extMatcher) match(data info.Getter) bool {
+	if len(m) == 0 {
+		return true
+	}
+	ext, _ := data.Get("ext")
+	return slices.Contains(m, strings.ToLower(ext))
+}
+
+type requiredRule struct {
+	ext  extMatcher
+	keys []string
+}
+
+type formatRule struct {
+	ext      extMatcher
+	key      string
+	pattern  *regexp.Regexp
+	typ      meta.ValueType
+	expected string
+}
+
+type consistentRule struct {
+	ext  extMatcher
+	keys []string
+}
+
+type disallowedRule struct {
+	ext   extMatcher
+	keys  []string
+	chars string
+}
+
+var valueTypes = map[string]meta.ValueType{
+	"int":      meta.IntType,
+	"float":    meta.FloatType,
+	"duration": meta.DurationType,
+	"date":     meta.DateType,
+}
+
+func New(c Config) (*Linter, error) {
+	l := &Linter{
+		dirValues: map[dirValueKey]map[string][]string{},
+	}
+	for i, x := range c.Required {
+		if len(x.Keys) == 0 {
+			return nil, fmt.Errorf("%w: no keys in required[%d]", ErrConfig, i)
+		}
+		l.required = append(l.required, &requiredRule{
+			ext:  newExtMatcher(x.Ext),
+			keys: x.Keys,
+		})
+	}
+	for i, x := range c.Format {
+		if x.Key == "" {
+			return nil, fmt.Errorf("%w: no key in format[%d]", ErrConfig, i)
+		}
+		r := &formatRule{
+			ext: newExtMatcher(x.Ext),
+			key: x.Key,
+			typ: meta.StringType,
+		}
+		var expected []string
+		if x.Pattern != "" {
+			p, err := regexp.Compile(x.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: format[%d]: %w", ErrConfig, i, err)
+			}
+			r.pattern = p
+			expected = append(expected, x.Pattern)
+		}
+		if x.Type != "" {
+			t, ok := valueTypes[x.Type]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown type %s in format[%d]", ErrConfig, x.Type, i)
+			}
+			r.typ = t
+			expected = append(expected, x.Type)
+		}
+		if len(expected) == 0 {
+			return nil, fmt.Errorf("%w: no pattern and type in format[%d]", ErrConfig, i)
+		}
+		r.expected = strings.Join(expected, " ")
+		l.format = append(l.format, r)
+	}
+	for i, x := range c.Consistent {
+		if len(x.Keys) == 0 {
+			return nil, fmt.Errorf("%w: no keys in consistent[%d]", ErrConfig, i)
+		}
+		l.consistent = append(l.consistent, &consistentRule{
+			ext:  newExtMatcher(x.Ext),
+			keys: x.Keys,
+		})
+	}
+	for i, x := range c.Disallowed {
+		if x.Chars == "" {
+			return nil, fmt.Errorf("%w: no chars in disallowed[%d]", ErrConfig, i)
+		}
+		l.disallowed = append(l.disallowed, &disallowedRule{
+			ext:   newExtMatcher(x.Ext),
+			keys:  x.Keys,
+			chars: x.Chars,
+		})
+	}
+	return l, nil
+}
+
+// Lint returns the violations of the file.
+// The violations of the consistent rules are returned by Flush.
+func (l *Linter) Lint(data info.Getter) []*Violation {
+	path, _ := data.Get("path")
+	var r []*Violation
+	for _, x := range l.required {
+		if !x.ext.match(data) {
+			continue
+		}
+		for _, k := range x.keys {
+			if _, ok := data.Get(k); !ok {
+				r = append(r, &Violation{
+					Path: path,
+					Rule: RuleRequired,
+					Key:  k,
+				})
+			}
+		}
+	}
+	for _, x := range l.format {
+		if !x.ext.match(data) {
+			continue
+		}
+		v, ok := data.Get(x.key)
+		if !ok {
+			continue
+		}
+		if (x.pattern == nil || x.pattern.MatchString(v)) && meta.TypesOf(v).Contains(x.typ) {
+			continue
+		}
+		r = append(r, &Violation{
+			Path:     path,
+			Rule:     RuleFormat,
+			Key:      x.key,
+			Value:    v,
+			Expected: x.expected,
+		})
+	}
+	for _, x := range l.disallowed {
+		if !x.ext.match(data) {
+			continue
+		}
+		r = append(r, x.lint(path, data)...)
+	}
+	for i, x := range l.consistent {
+		if !x.ext.match(data) {
+			continue
+		}
+		dir, _ := data.Get("dir")
+		for _, k := range x.keys {
+			v, _ := data.Get(k)
+			l.addDirValue(dirValueKey{
+				dir:  dir,
+				rule: i,
+				key:  k,
+			}, v, path)
+		}
+	}
+	return r
+}
+
+func (x *disallowedRule) lint(path string, data info.Getter) []*Violation {
+	keys := x.keys
+	if len(keys) == 0 {
+		m, err := info.ToMap(data)
+		if err != nil {
+			return nil
+		}
+		keys = slices.Sorted(maps.Keys(m))
+	}
+	var r []*Violation
+	for _, k := range keys {
+		v, ok := data.Get(k)
+		if !ok || !strings.ContainsAny(v, x.chars) {
+			continue
+		}
+		r = append(r, &Violation{
+			Path:     path,
+			Rule:     RuleDisallowed,
+			Key:      k,
+			Value:    v,
+			Expected: fmt.Sprintf("no %q", x.chars),
+		})
+	}
+	return r
+}
+
+func (l *Linter) addDirValue(key dirValueKey, value, path string) {
+	if _, ok := l.dirValues[key]; !ok {
+		l.dirValues[key] = map[string][]string{}
+	}
+	l.dirValues[key][value] = append(l.dirValues[key][value], path)
+}
+
+// Flush returns the violations of the consistent rules.
+// The files whose values differ from the most common value within the directory are violations.
+func (l *Linter) Flush() []*Violation {
+	var r []*Violation
+	for _, key := range slices.SortedFunc(maps.Keys(l.dirValues), dirValueKey.compare) {
+		values := l.dirValues[key]
+		if len(values) < 2 {
+			continue
+		}
+		keys := slices.SortedFunc(maps.Keys(values), func(a, b string) int {
+			if c := cmp.Compare(len(values[b]), len(values[a])); c != 0 {
+				return c
+			}
+			return cmp.Compare(a, b)
+		})
+		expected := keys[0]
+		for _, v := range keys[1:] {
+			for _, path := range slices.Sorted(slices.Values(values[v])) {
+				r = append(r, &Violation{
+					Path:     path,
+					Rule:     RuleConsistent,
+					Key:      key.key,
+					Value:    v,
+					Expected: expected,
+				})
+			}
+		}
+	}
+	clear(l.dirValues)
+	return r
+}
@@ -0,0 +1,185 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/lint"
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinter(t *testing.T) {
+	newData := func(d map[string]string) info.Getter {
+		return info.New(meta.NewData(d))
+	}
+
+	linter, err := lint.New(lint.Config{
+		Required: []lint.RequiredRule{
+			{
+				Ext:  []string{"mp3", ".FLAC"},
+				Keys: []string{"artist", "title"},
+			},
+		},
+		Format: []lint.FormatRule{
+			{
+				Key:     "track",
+				Pattern: `^\d+(/\d+)?$`,
+			},
+			{
+				Key:  "date",
+				Type: "date",
+			},
+		},
+		Consistent: []lint.ConsistentRule{
+			{
+				Keys: []string{"album"},
+			},
+		},
+		Disallowed: []lint.DisallowedRule{
+			{
+				Keys:  []string{"title"},
+				Chars: `?*`,
+			},
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, tc := range []struct {
+		title string
+		data  info.Getter
+		want  []*lint.Violation
+	}{
+		{
+			title: "ok",
+			data: newData(map[string]string{
+				"path":   "/m/a/1.flac",
+				"dir":    "/m/a",
+				"ext":    ".flac",
+				"artist": "A",
+				"title":  "T1",
+				"track":  "1/10",
+				"date":   "2024-01-02",
+				"album":  "ALBUM",
+			}),
+		},
+		{
+			title: "not applied",
+			data: newData(map[string]string{
+				"path":  "/m/a/cover.jpg",
+				"dir":   "/m/b",
+				"ext":   ".jpg",
+				"album": "ALBUM",
+			}),
+		},
+		{
+			title: "violations",
+			data: newData(map[string]string{
+				"path":  "/m/a/2.mp3",
+				"dir":   "/m/a",
+				"ext":   ".mp3",
+				"title": "What?",
+				"track": "two",
+				"date":  "yesterday",
+				"album": "ALBUM",
+			}),
+			want: []*lint.Violation{
+				{Path: "/m/a/2.mp3", Rule: lint.RuleRequired, Key: "artist"},
+				{Path: "/m/a/2.mp3", Rule: lint.RuleFormat, Key: "track", Value: "two", Expected: `^\d+(/\d+)?$`},
+				{Path: "/m/a/2.mp3", Rule: lint.RuleFormat, Key: "date", Value: "yesterday", Expected: "date"},
+				{Path: "/m/a/2.mp3", Rule: lint.RuleDisallowed, Key: "title", Value: "What?", Expected: `no "?*"`},
+			},
+		},
+		{
+			title: "inconsistent",
+			data: newData(map[string]string{
+				"path":   "/m/a/3.flac",
+				"dir":    "/m/a",
+				"ext":    ".flac",
+				"artist": "A",
+				"title":  "T3",
+				"album":  "ALBUM (Disc 2)",
+			}),
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, linter.Lint(tc.data))
+		})
+	}
+
+	assert.Equal(t, []*lint.Violation{
+		{Path: "/m/a/3.flac", Rule: lint.RuleConsistent, Key: "album", Value: "ALBUM (Disc 2)", Expected: "ALBUM"},
+	}, linter.Flush())
+}
+
+func TestLinterConsistentPerRule(t *testing.T) {
+	linter, err := lint.New(lint.Config{
+		Consistent: []lint.ConsistentRule{
+			{
+				Ext:  []string{"mp3"},
+				Keys: []string{"album"},
+			},
+			{
+				Ext:  []string{"flac"},
+				Keys: []string{"album"},
+			},
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, d := range []map[string]string{
+		{"path": "/m/a/1.mp3", "ext": ".mp3", "album": "ALBUM (MP3)"},
+		{"path": "/m/a/2.mp3", "ext": ".mp3", "album": "ALBUM (MP3)"},
+		{"path": "/m/a/1.flac", "ext": ".flac", "album": "ALBUM"},
+		{"path": "/m/a/2.flac", "ext": ".flac", "album": "ALBUM"},
+		{"path": "/m/a/3.flac", "ext": ".flac", "album": "ALBUM (Disc 2)"},
+	} {
+		d["dir"] = "/m/a"
+		assert.Nil(t, linter.Lint(info.New(meta.NewData(d))))
+	}
+	// the values of the rules are not mixed
+	assert.Equal(t, []*lint.Violation{
+		{Path: "/m/a/3.flac", Rule: lint.RuleConsistent, Key: "album", Value: "ALBUM (Disc 2)", Expected: "ALBUM"},
+	}, linter.Flush())
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, tc := range []struct {
+		title  string
+		config lint.Config
+	}{
+		{
+			title: "no required keys",
+			config: lint.Config{
+				Required: []lint.RequiredRule{{Ext: []string{"mp3"}}},
+			},
+		},
+		{
+			title: "invalid pattern",
+			config: lint.Config{
+				Format: []lint.FormatRule{{Key: "track", Pattern: `(`}},
+			},
+		},
+		{
+			title: "unknown type",
+			config: lint.Config{
+				Format: []lint.FormatRule{{Key: "track", Type: "number"}},
+			},
+		},
+		{
+			title: "no chars",
+			config: lint.Config{
+				Disallowed: []lint.DisallowedRule{{Keys: []string{"title"}}},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := lint.New(tc.config)
+			assert.ErrorIs(t, err, lint.ErrConfig)
+		})
+	}
+}
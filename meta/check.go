@@ -0,0 +1,148 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/fflist/metric"
+)
+
+// CheckResult is the result of decoding a media file.
+type CheckResult struct {
+	Path             string   `json:"path"`
+	OK               bool     `json:"ok"`
+	Errors           []string `json:"errors,omitempty"`
+	Truncated        bool     `json:"truncated"`
+	DurationMismatch bool     `json:"duration_mismatch"`
+	// Duration is the duration from the metadata in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	// DecodedDuration is the duration of the decoded streams in seconds.
+	DecodedDuration float64 `json:"decoded_duration,omitempty"`
+}
+
+// Checker decodes a media file to verify its integrity.
+type Checker interface {
+	// Check decodes the file.
+	// expected is the duration from the metadata, 0 means unknown.
+	// Returns an error only if the decoder cannot run.
+	Check(ctx context.Context, path string, expected time.Duration) (*CheckResult, error)
+}
+
+var (
+	_ Checker = &FFChecker{}
+)
+
+// FFChecker decodes file using ffmpeg.
+type FFChecker struct {
+	cmd       string
+	tolerance time.Duration
+}
+
+// NewChecker returns a new FFChecker.
+// tolerance is the acceptable difference between the expected and the decoded duration.
+func NewChecker(cmd string, tolerance time.Duration) *FFChecker {
+	return &FFChecker{
+		cmd:       cmd,
+		tolerance: tolerance,
+	}
+}
+
+var (
+	ErrCheck = errors.New("Check")
+
+	truncatedRegexp = regexp.MustCompile(`(?i)truncat|end of file|premature|partial file|incomplete frame|packet too small`)
+)
+
+func (c FFChecker) Check(ctx context.Context, path string, expected time.Duration) (*CheckResult, error) {
+	metric.IncrCheckCount()
+
+	var (
+		stdout bytes.Buffer
+		stderr bytes.Buffer
+		cmd    = exec.CommandContext(ctx, c.cmd,
+			"-v", "error", // log level
+			"-hide_banner",
+			"-nostdin",
+			"-nostats",
+			"-i", path,
+			"-progress", "pipe:1", // report out_time to stdout
+			"-f", "null", "-", // decode only
+		)
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		metric.IncrCheckFailedCount()
+		return nil, fmt.Errorf("%w: path %s: %w", ErrCheck, path, runErr)
+	}
+
+	r := &CheckResult{
+		Path:            path,
+		Duration:        expected.Seconds(),
+		DecodedDuration: parseProgressOutTime(stdout.Bytes()).Seconds(),
+	}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		r.Errors = append(r.Errors, line)
+		if truncatedRegexp.MatchString(line) {
+			r.Truncated = true
+		}
+	}
+	if runErr != nil {
+		r.Errors = append(r.Errors, runErr.Error())
+	}
+	if expected > 0 {
+		diff := math.Abs(r.DecodedDuration - r.Duration)
+		r.DurationMismatch = diff > c.tolerance.Seconds()
+	}
+	r.OK = len(r.Errors) == 0 && !r.Truncated && !r.DurationMismatch
+
+	if r.OK {
+		metric.IncrCheckSuccessCount()
+	} else {
+		metric.IncrCheckFailedCount()
+	}
+	return r, nil
+}
+
+// parseProgressOutTime returns the last out_time_us of ffmpeg -progress.
+func parseProgressOutTime(b []byte) time.Duration {
+	var (
+		r       time.Duration
+		scanner = bufio.NewScanner(bytes.NewReader(b))
+	)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || k != "out_time_us" {
+			continue
+		}
+		if us, err := strconv.ParseInt(v, 10, 64); err == nil && us > 0 {
+			r = time.Duration(us) * time.Microsecond
+		}
+	}
+	return r
+}
+
+// ParseSeconds parses the duration in seconds, e.g. 123.456000 of ffprobe.
+func ParseSeconds(s string) (time.Duration, error) {
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(x * float64(time.Second)), nil
+}
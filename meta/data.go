@@ -2,6 +2,7 @@ package meta
 
 import (
 	"encoding/json"
+	"iter"
 	"maps"
 )
 
@@ -28,6 +29,11 @@ func (d Data) Get(key string) (string, bool) {
 	return x, ok
 }
 
+// All returns all the keys and values.
+func (d Data) All() iter.Seq2[string, string] {
+	return maps.All(d.d)
+}
+
 func (d Data) Merge(right *Data) *Data {
 	if right == nil {
 		return &d
@@ -0,0 +1,82 @@
+package meta
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/berquerant/fflist/metric"
+)
+
+var (
+	_ Prober         = &FixtureProber{}
+	_ ReaderProber   = &FixtureProber{}
+	_ UnderlayProber = &FixtureProber{}
+)
+
+// FixtureProber returns the metadata from the fixture instead of probing files.
+// This is for tests and reproducing bug reports without the media files.
+// The metadata of the existing files, e.g. size and mod_time, win over the fixture.
+type FixtureProber struct {
+	d map[string]map[string]string
+}
+
+// NewFixtureProber returns a new FixtureProber from the metadata by path.
+func NewFixtureProber(d map[string]map[string]string) *FixtureProber {
+	return &FixtureProber{
+		d: d,
+	}
+}
+
+// ReadFixture reads the metadata in jsonl format, the same format as the index.
+// Each line should have the path.
+func ReadFixture(r io.Reader) (*FixtureProber, error) {
+	var (
+		d       = map[string]map[string]string{}
+		scanner = bufio.NewScanner(r)
+		lineNum int
+	)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024)
+
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		x := map[string]string{}
+		if err := json.Unmarshal(scanner.Bytes(), &x); err != nil {
+			return nil, fmt.Errorf("%w: fixture line %d: %w", ErrProbe, lineNum, err)
+		}
+		path, ok := x["path"]
+		if !ok {
+			return nil, fmt.Errorf("%w: fixture line %d: no path", ErrProbe, lineNum)
+		}
+		d[path] = x
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Join(ErrProbe, err)
+	}
+	return NewFixtureProber(d), nil
+}
+
+func (p FixtureProber) Probe(_ context.Context, path string) (*Data, error) {
+	metric.IncrProbeCount()
+
+	x, ok := p.d[path]
+	if !ok {
+		metric.IncrProbeFailedCount()
+		return nil, fmt.Errorf("%w: not found in fixture: path %s", ErrProbe, path)
+	}
+
+	metric.IncrProbeSuccessCount()
+	return NewData(x), nil
+}
+
+func (p FixtureProber) ProbeReader(ctx context.Context, path string, _ io.Reader) (*Data, error) {
+	return p.Probe(ctx, path)
+}
+
+func (FixtureProber) Underlay() {}
@@ -0,0 +1,88 @@
+package meta_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFixture(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		input string
+		path  string
+		want  map[string]string
+		err   bool
+	}{
+		{
+			title: "found",
+			input: `{"path":"a.mp3","artist":"A"}
+
+{"path":"b.mp3","artist":"B","size":"10"}`,
+			path: "b.mp3",
+			want: map[string]string{
+				"path":   "b.mp3",
+				"artist": "B",
+				"size":   "10",
+			},
+		},
+		{
+			title: "later line wins",
+			input: `{"path":"a.mp3","artist":"A"}
+{"path":"a.mp3","artist":"B"}`,
+			path: "a.mp3",
+			want: map[string]string{
+				"path":   "a.mp3",
+				"artist": "B",
+			},
+		},
+		{
+			title: "not found",
+			input: `{"path":"a.mp3","artist":"A"}`,
+			path:  "b.mp3",
+		},
+		{
+			title: "no path",
+			input: `{"artist":"A"}`,
+			err:   true,
+		},
+		{
+			title: "invalid json",
+			input: `{"path":`,
+			err:   true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			p, err := meta.ReadFixture(bytes.NewBufferString(tc.input))
+			if tc.err {
+				assert.ErrorIs(t, err, meta.ErrProbe)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			for _, probe := range []func() (*meta.Data, error){
+				func() (*meta.Data, error) { return p.Probe(context.TODO(), tc.path) },
+				func() (*meta.Data, error) { return p.ProbeReader(context.TODO(), tc.path, bytes.NewBufferString("")) },
+			} {
+				got, err := probe()
+				if tc.want == nil {
+					assert.ErrorIs(t, err, meta.ErrProbe)
+					continue
+				}
+				if !assert.Nil(t, err) {
+					continue
+				}
+				for k, v := range tc.want {
+					x, ok := got.Get(k)
+					assert.True(t, ok, k)
+					assert.Equal(t, v, x, k)
+				}
+			}
+		})
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os/exec"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/berquerant/fflist/logx"
 	"github.com/berquerant/fflist/metric"
 )
 
@@ -15,29 +20,152 @@ type Prober interface {
 	Probe(ctx context.Context, path string) (*Data, error)
 }
 
+// ReaderProber reads the content from the reader instead of the path,
+// e.g. a member of an archive.
+type ReaderProber interface {
+	ProbeReader(ctx context.Context, path string, r io.Reader) (*Data, error)
+}
+
+// UnderlayProber is a Prober whose metadata is overwritten by the metadata of the file, e.g. size and mod_time,
+// because it may be recorded before the file changes.
+type UnderlayProber interface {
+	Prober
+	Underlay()
+}
+
 var (
-	_ Prober = &FFProber{}
+	_ Prober       = &FFProber{}
+	_ ReaderProber = &FFProber{}
 )
 
-func NewProber(cmd string) *FFProber {
-	return &FFProber{
+func NewProber(cmd string, opt ...ProberOption) *FFProber {
+	p := &FFProber{
 		cmd: cmd,
 	}
+	for _, f := range opt {
+		f(p)
+	}
+	return p
+}
+
+type ProberOption func(*FFProber)
+
+// WithTimeout kills ffprobe if it takes longer than d per file.
+// 0 means no timeout.
+func WithTimeout(d time.Duration) ProberOption {
+	return func(p *FFProber) {
+		p.timeout = d
+	}
+}
+
+// WithRetry retries ffprobe at most n times on transient errors, e.g. I/O errors.
+// The timeout and the content from the reader (ProbeReader) are not retried.
+func WithRetry(n int, interval time.Duration) ProberOption {
+	return func(p *FFProber) {
+		p.retry = n
+		p.retryInterval = interval
+	}
+}
+
+// WithSections shows the sections in addition to format, e.g. streams, chapters, programs.
+// The section can specify entries like -show_entries, e.g. stream=codec_name,codec_type.
+// The values of the sections are flattened into namespaced keys, e.g. streams.0.codec_name.
+func WithSections(sections ...string) ProberOption {
+	return func(p *FFProber) {
+		p.sections = append(p.sections, sections...)
+	}
+}
+
+// WithAnalyzeDuration sets -analyzeduration.
+func WithAnalyzeDuration(v string) ProberOption {
+	return func(p *FFProber) {
+		p.analyzeDuration = v
+	}
+}
+
+// WithProbeSize sets -probesize.
+func WithProbeSize(v string) ProberOption {
+	return func(p *FFProber) {
+		p.probeSize = v
+	}
+}
+
+// WithArgs appends the arguments before the input.
+func WithArgs(args ...string) ProberOption {
+	return func(p *FFProber) {
+		p.args = append(p.args, args...)
+	}
 }
 
 // FFProber reads file using ffprobe and returns a metadata.
 type FFProber struct {
-	cmd string
+	cmd             string
+	timeout         time.Duration
+	retry           int
+	retryInterval   time.Duration
+	sections        []string
+	analyzeDuration string
+	probeSize       string
+	args            []string
+	// entries overwrites -show_entries
+	entries string
 }
 
 var (
 	ErrProbe = errors.New("Probe")
 )
 
+// Error is the error of the media analyzer.
+type Error struct {
+	Err    error
+	Stderr string
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Stderr returns the stderr of the media analyzer in the error chain.
+func Stderr(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Stderr
+	}
+	return ""
+}
+
+// isTransient returns true if the error may not happen on retry.
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the file that takes too long will take too long again
+		return false
+	}
+	stderr := Stderr(err)
+	for _, x := range []string{
+		"Input/output error",
+		"Resource temporarily unavailable",
+		"Connection timed out",
+		"Stale file handle",
+	} {
+		if strings.Contains(stderr, x) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p FFProber) Probe(ctx context.Context, path string) (*Data, error) {
+	return p.probeAndFormat(ctx, path, path, nil)
+}
+
+// ProbeReader streams the content to ffprobe stdin.
+func (p FFProber) ProbeReader(ctx context.Context, path string, r io.Reader) (*Data, error) {
+	return p.probeAndFormat(ctx, path, "pipe:0", r)
+}
+
+func (p FFProber) probeAndFormat(ctx context.Context, path, input string, stdin io.Reader) (*Data, error) {
 	metric.IncrProbeCount()
 
-	b, err := p.probe(ctx, path)
+	b, err := p.probeWithRetry(ctx, input, stdin)
 	if err != nil {
 		metric.IncrProbeFailedCount()
 		return nil, fmt.Errorf("%w: path %s", err, path)
@@ -53,17 +181,115 @@ func (p FFProber) Probe(ctx context.Context, path string) (*Data, error) {
 	return d, nil
 }
 
-func (p FFProber) probe(ctx context.Context, path string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, p.cmd,
+func (p FFProber) probeWithRetry(ctx context.Context, input string, stdin io.Reader) ([]byte, error) {
+	for i := 0; ; i++ {
+		b, err := p.probeWithTimeout(ctx, input, stdin)
+		if err == nil || i >= p.retry || stdin != nil || ctx.Err() != nil || !isTransient(err) {
+			return b, err
+		}
+
+		metric.IncrProbeRetryCount()
+		slog.Debug("FFProber retry", slog.String("input", input), slog.Int("n", i+1), logx.Err(err))
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(err, ctx.Err())
+		case <-time.After(p.retryInterval):
+		}
+	}
+}
+
+func (p FFProber) probeWithTimeout(ctx context.Context, input string, stdin io.Reader) ([]byte, error) {
+	if p.timeout <= 0 {
+		return p.probe(ctx, input, stdin)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	b, err := p.probe(ctx, input, stdin)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		metric.IncrProbeTimeoutCount()
+		return nil, errors.Join(err, fmt.Errorf("timeout %s", p.timeout), context.DeadlineExceeded)
+	}
+	return b, err
+}
+
+// showEntries returns the argument of -show_entries.
+// The sections of the same name are merged, e.g. chapters by --chapters and --probe-section.
+func (p FFProber) showEntries() string {
+	if p.entries != "" {
+		return p.entries
+	}
+	var (
+		names = []string{"format"} // display file format
+		// nil means all the entries of the section
+		entries = map[string][]string{"format": nil}
+	)
+	for _, x := range p.sections {
+		name, fields, found := strings.Cut(x, "=")
+		// -show_entries requires singular
+		switch name {
+		case "streams", "chapters", "programs":
+			name = strings.TrimSuffix(name, "s")
+		}
+		xs, seen := entries[name]
+		if !seen {
+			names = append(names, name)
+		}
+		switch {
+		case seen && xs == nil:
+		case !found:
+			entries[name] = nil
+		default:
+			for _, f := range strings.Split(fields, ",") {
+				if !slices.Contains(xs, f) {
+					xs = append(xs, f)
+				}
+			}
+			entries[name] = xs
+		}
+	}
+
+	r := make([]string, len(names))
+	for i, name := range names {
+		r[i] = name
+		if xs := entries[name]; xs != nil {
+			r[i] += "=" + strings.Join(xs, ",")
+		}
+	}
+	return strings.Join(r, ":")
+}
+
+func (p FFProber) arguments(input string) []string {
+	r := []string{
 		"-v", "error", // log level
 		"-hide_banner",
-		"-show_entries", "format", // display file format
+		"-show_entries", p.showEntries(),
 		"-of", "json=c=1", // as compact json
-		path,
-	)
+	}
+	if p.analyzeDuration != "" {
+		r = append(r, "-analyzeduration", p.analyzeDuration)
+	}
+	if p.probeSize != "" {
+		r = append(r, "-probesize", p.probeSize)
+	}
+	r = append(r, p.args...)
+	return append(r, input)
+}
+
+func (p FFProber) probe(ctx context.Context, input string, stdin io.Reader) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, p.cmd, p.arguments(input)...)
+	cmd.Stdin = stdin
+	cmd.WaitDelay = time.Second
 	x, err := cmd.Output()
 	if err != nil {
-		return nil, errors.Join(ErrProbe, err)
+		var stderr string
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = string(exitErr.Stderr)
+		}
+		return nil, &Error{
+			Err:    errors.Join(ErrProbe, err),
+			Stderr: stderr,
+		}
 	}
 	return x, nil
 }
@@ -114,5 +340,64 @@ func (FFProber) formatData(b []byte, path string) (*Data, error) {
 		}
 	}
 
+	// extra sections, e.g. streams
+	for k, v := range d {
+		if k == "format" {
+			continue
+		}
+		flatten(k, v, w)
+	}
+	if x, ok := d["chapters"].([]any); ok {
+		summarizeChapters(x, w)
+	}
+
 	return NewData(r), nil
 }
+
+// MultiValue formats the values of multi-valued keys, e.g. chapter_title, as a JSON array
+// so that the values containing newlines do not break the line-oriented output.
+// Returns an empty string if there are no values.
+func MultiValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// summarizeChapters writes chapter_count and the multi-valued keys of the chapters.
+func summarizeChapters(chapters []any, w func(string, any)) {
+	var titles, starts, ends []string
+	for _, x := range chapters {
+		c, _ := x.(map[string]any)
+		var title string
+		if tags, ok := c["tags"].(map[string]any); ok {
+			if t, ok := tags["title"]; ok {
+				title = fmt.Sprint(t)
+			}
+		}
+		titles = append(titles, title)
+		starts = append(starts, fmt.Sprint(c["start_time"]))
+		ends = append(ends, fmt.Sprint(c["end_time"]))
+	}
+	w("chapter_count", len(chapters))
+	w("chapter_title", MultiValue(titles))
+	w("chapter_start", MultiValue(starts))
+	w("chapter_end", MultiValue(ends))
+}
+
+// flatten writes the nested value as namespaced keys, e.g. streams.0.tags.language.
+func flatten(prefix string, value any, w func(string, any)) {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			flatten(prefix+"."+k, v, w)
+		}
+	case []any:
+		for i, v := range value {
+			flatten(fmt.Sprintf("%s.%d", prefix, i), v, w)
+		}
+	default:
+		w(prefix, value)
+	}
+}
@@ -0,0 +1,340 @@
+package meta_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeProbe writes a fake ffprobe that runs script with $n, the number of the calls.
+// Returns the command and the function to get the number of the calls.
+func newFakeProbe(t *testing.T, script string) (string, func() int) {
+	var (
+		d     = t.TempDir()
+		cmd   = filepath.Join(d, "ffprobe")
+		count = filepath.Join(d, "count")
+	)
+	content := fmt.Sprintf(`#!/bin/sh
+n=$(cat %[1]q 2>/dev/null || echo 0)
+n=$((n+1))
+echo $n > %[1]q
+%[2]s
+`, count, script)
+	if err := os.WriteFile(cmd, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return cmd, func() int {
+		b, _ := os.ReadFile(count)
+		n, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+		return n
+	}
+}
+
+func TestFFProberRetry(t *testing.T) {
+	const (
+		ok         = `echo '{"format":{"duration":"1.5"}}'`
+		transient  = `echo "Input/output error" >&2; exit 1`
+		permanent  = `echo "Invalid data found" >&2; exit 1`
+		sleep      = `exec sleep 5`
+		okOnSecond = `if [ $n -lt 2 ] ; then ` + transient + ` ; fi; ` + ok
+	)
+
+	for _, tc := range []struct {
+		title   string
+		script  string
+		opt     []meta.ProberOption
+		reader  bool
+		calls   int
+		err     bool
+		timeout bool
+		stderr  string
+	}{
+		{
+			title:  "success",
+			script: ok,
+			opt:    []meta.ProberOption{meta.WithRetry(2, 0)},
+			calls:  1,
+		},
+		{
+			title:  "retry transient error",
+			script: okOnSecond,
+			opt:    []meta.ProberOption{meta.WithRetry(2, 0)},
+			calls:  2,
+		},
+		{
+			title:  "retries exhausted",
+			script: transient,
+			opt:    []meta.ProberOption{meta.WithRetry(2, 0)},
+			calls:  3,
+			err:    true,
+			stderr: "Input/output error\n",
+		},
+		{
+			title:  "no retry",
+			script: okOnSecond,
+			calls:  1,
+			err:    true,
+			stderr: "Input/output error\n",
+		},
+		{
+			title:  "no retry of permanent error",
+			script: permanent,
+			opt:    []meta.ProberOption{meta.WithRetry(2, 0)},
+			calls:  1,
+			err:    true,
+			stderr: "Invalid data found\n",
+		},
+		{
+			title:  "no retry of reader",
+			script: okOnSecond,
+			opt:    []meta.ProberOption{meta.WithRetry(2, 0)},
+			reader: true,
+			calls:  1,
+			err:    true,
+			stderr: "Input/output error\n",
+		},
+		{
+			title:   "no retry of timeout",
+			script:  sleep,
+			opt:     []meta.ProberOption{meta.WithRetry(2, 0), meta.WithTimeout(100 * time.Millisecond)},
+			calls:   1,
+			err:     true,
+			timeout: true,
+		},
+		{
+			title:  "within timeout",
+			script: ok,
+			opt:    []meta.ProberOption{meta.WithTimeout(5 * time.Second)},
+			calls:  1,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cmd, calls := newFakeProbe(t, tc.script)
+			p := meta.NewProber(cmd, tc.opt...)
+
+			var (
+				got *meta.Data
+				err error
+			)
+			if tc.reader {
+				got, err = p.ProbeReader(context.TODO(), "a.mp3", bytes.NewBufferString(""))
+			} else {
+				got, err = p.Probe(context.TODO(), "a.mp3")
+			}
+			assert.Equal(t, tc.calls, calls())
+			if tc.err {
+				assert.ErrorIs(t, err, meta.ErrProbe)
+				assert.Equal(t, tc.timeout, errors.Is(err, context.DeadlineExceeded))
+				assert.Equal(t, tc.stderr, meta.Stderr(err))
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			v, _ := got.Get("duration")
+			assert.Equal(t, "1.5", v)
+		})
+	}
+}
+
+func TestParseSeconds(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  time.Duration
+		err   bool
+	}{
+		{input: "123.456000", want: 123456 * time.Millisecond},
+		{input: "0", want: 0},
+		{input: "N/A", err: true},
+		{input: "", err: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := meta.ParseSeconds(tc.input)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFFProberArguments(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		opt   []meta.ProberOption
+		want  []string
+	}{
+		{
+			title: "default",
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "sections",
+			opt:   []meta.ProberOption{meta.WithSections("streams", "chapters", "program_version")},
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format:stream:chapter:program_version", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "section entries",
+			opt:   []meta.ProberOption{meta.WithSections("stream=codec_name,codec_type")},
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format:stream=codec_name,codec_type", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "duplicated sections",
+			opt:   []meta.ProberOption{meta.WithSections("chapters", "format", "chapters")},
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format:chapter", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "merge section entries",
+			opt:   []meta.ProberOption{meta.WithSections("stream=codec_name", "streams=codec_type,codec_name")},
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format:stream=codec_name,codec_type", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "all entries win",
+			opt:   []meta.ProberOption{meta.WithSections("stream=codec_name", "streams", "stream=codec_type")},
+			want:  []string{"-v", "error", "-hide_banner", "-show_entries", "format:stream", "-of", "json=c=1", "a.mp3"},
+		},
+		{
+			title: "options",
+			opt: []meta.ProberOption{
+				meta.WithAnalyzeDuration("10M"),
+				meta.WithProbeSize("5M"),
+				meta.WithArgs("-select_streams", "a"),
+			},
+			want: []string{
+				"-v", "error", "-hide_banner", "-show_entries", "format", "-of", "json=c=1",
+				"-analyzeduration", "10M", "-probesize", "5M", "-select_streams", "a", "a.mp3",
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cmd, _ := newFakeProbe(t, `printf '%s\n' "$@" > "$(dirname "$0")/args"
+echo '{"format":{}}'`)
+			_, err := meta.NewProber(cmd, tc.opt...).Probe(context.TODO(), "a.mp3")
+			if !assert.Nil(t, err) {
+				return
+			}
+			b, err := os.ReadFile(filepath.Join(filepath.Dir(cmd), "args"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"))
+		})
+	}
+}
+
+func TestFFProberFormat(t *testing.T) {
+	for _, tc := range []struct {
+		title  string
+		output string
+		want   map[string]string
+		err    bool
+	}{
+		{
+			title:  "format",
+			output: `{"format":{"duration":"1.5","nb_streams":1,"tags":{"artist":"A","title":"T"}}}`,
+			want: map[string]string{
+				"duration":   "1.5",
+				"nb_streams": "1",
+				"artist":     "A",
+				"title":      "T",
+			},
+		},
+		{
+			title:  "flatten sections",
+			output: `{"format":{},"streams":[{"codec_name":"flac","tags":{"language":"jpn"}},{"codec_name":"png","disposition":{"attached_pic":1}}]}`,
+			want: map[string]string{
+				"streams.0.codec_name":               "flac",
+				"streams.0.tags.language":            "jpn",
+				"streams.1.codec_name":               "png",
+				"streams.1.disposition.attached_pic": "1",
+			},
+		},
+		{
+			title:  "chapters",
+			output: `{"format":{},"chapters":[{"start_time":"0.000000","end_time":"60.000000","tags":{"title":"Prologue"}},{"start_time":"60.000000","end_time":"90.000000","tags":{"title":"Part 1\nPart 2"}},{"start_time":"90.000000","end_time":"120.000000"}]}`,
+			want: map[string]string{
+				"chapters.0.start_time": "0.000000",
+				"chapters.0.end_time":   "60.000000",
+				"chapters.0.tags.title": "Prologue",
+				"chapters.1.start_time": "60.000000",
+				"chapters.1.end_time":   "90.000000",
+				"chapters.1.tags.title": "Part 1\nPart 2",
+				"chapters.2.start_time": "90.000000",
+				"chapters.2.end_time":   "120.000000",
+				"chapter_count":         "3",
+				"chapter_title":         `["Prologue","Part 1\nPart 2",""]`,
+				"chapter_start":         `["0.000000","60.000000","90.000000"]`,
+				"chapter_end":           `["60.000000","90.000000","120.000000"]`,
+			},
+		},
+		{
+			title:  "no chapters",
+			output: `{"format":{},"chapters":[]}`,
+			want: map[string]string{
+				"chapter_count": "0",
+				"chapter_title": "",
+				"chapter_start": "",
+				"chapter_end":   "",
+			},
+		},
+		{
+			title:  "no format",
+			output: `{"streams":[]}`,
+			err:    true,
+		},
+		{
+			title:  "invalid tags",
+			output: `{"format":{"tags":"x"}}`,
+			err:    true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cmd, _ := newFakeProbe(t, fmt.Sprintf("printf '%%s' %q", tc.output))
+			got, err := meta.NewProber(cmd).Probe(context.TODO(), "a.mp3")
+			if tc.err {
+				assert.ErrorIs(t, err, meta.ErrProbe)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			b, err := json.Marshal(got)
+			if !assert.Nil(t, err) {
+				return
+			}
+			var m map[string]string
+			if !assert.Nil(t, json.Unmarshal(b, &m)) {
+				return
+			}
+			assert.Equal(t, tc.want, m)
+		})
+	}
+}
+
+func TestFFProberReadTags(t *testing.T) {
+	output := `{"format":{"tags":{"artist":"A","title":"T"}},"streams":[{"tags":{"title":"S0","language":"jpn"}},{"tags":{"language":"eng","comment":"C"}}]}`
+	cmd, _ := newFakeProbe(t, fmt.Sprintf("printf '%%s' %q", output))
+	got, err := meta.NewProber(cmd, meta.WithSections("streams")).ReadTags(context.TODO(), "in.flac")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{
+		"artist":   "A",
+		"title":    "T",
+		"language": "jpn",
+		"comment":  "C",
+	}, got)
+}
@@ -0,0 +1,114 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+)
+
+// TagWriter writes the tags of a media file.
+type TagWriter interface {
+	// WriteTags copies input to output with the tags.
+	// The nil value removes the tag.
+	WriteTags(ctx context.Context, input, output string, tags map[string]*string) error
+}
+
+// TagReader reads the tags of a media file.
+type TagReader interface {
+	// ReadTags returns the tags of the format and the streams,
+	// excluding the other metadata, e.g. duration and size.
+	ReadTags(ctx context.Context, path string) (map[string]string, error)
+}
+
+var (
+	_ TagWriter = &FFTagWriter{}
+	_ TagReader = &FFProber{}
+)
+
+var (
+	ErrTag = errors.New("Tag")
+)
+
+// FFTagWriter writes tags using ffmpeg without re-encoding.
+type FFTagWriter struct {
+	cmd string
+}
+
+func NewTagWriter(cmd string) *FFTagWriter {
+	return &FFTagWriter{
+		cmd: cmd,
+	}
+}
+
+func (w FFTagWriter) arguments(input, output string, tags map[string]*string) []string {
+	r := []string{
+		"-v", "error", // log level
+		"-hide_banner",
+		"-nostdin",
+		"-nostats",
+		"-y",
+		"-i", input,
+		"-map", "0", // all streams, e.g. cover art
+		"-c", "copy",
+		"-map_metadata", "0",
+	}
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		var v string
+		if x := tags[k]; x != nil {
+			v = *x
+		}
+		// empty value removes the tag
+		r = append(r, "-metadata", fmt.Sprintf("%s=%s", k, v))
+	}
+	return append(r, output)
+}
+
+func (w FFTagWriter) WriteTags(ctx context.Context, input, output string, tags map[string]*string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, w.cmd, w.arguments(input, output, tags)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &Error{
+			Err:    fmt.Errorf("%w: path %s: %w", ErrTag, input, err),
+			Stderr: stderr.String(),
+		}
+	}
+	return nil
+}
+
+// ReadTags reads the tags of the format and the streams.
+// The tags of the format win over the tags of the streams, e.g. the tags of ogg are of the stream.
+func (p FFProber) ReadTags(ctx context.Context, path string) (map[string]string, error) {
+	p.entries = "format_tags:stream_tags"
+	b, err := p.probeWithRetry(ctx, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: path %s", err, path)
+	}
+	var d struct {
+		Format struct {
+			Tags map[string]any `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			Tags map[string]any `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("%w: path %s: %w", ErrProbe, path, err)
+	}
+
+	r := map[string]string{}
+	for _, s := range slices.Backward(d.Streams) {
+		for k, v := range s.Tags {
+			r[k] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range d.Format.Tags {
+		r[k] = fmt.Sprint(v)
+	}
+	return r, nil
+}
@@ -0,0 +1,83 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Preset is the output options of the transcoding.
+type Preset struct {
+	// Ext is the extension of the output, e.g. .mp3.
+	Ext string `json:"ext" yaml:"ext"`
+	// Args are the output options of ffmpeg, e.g. -c:a libmp3lame -b:a 320k.
+	Args []string `json:"args" yaml:"args"`
+}
+
+func (p Preset) Validate() error {
+	if p.Ext == "" {
+		return fmt.Errorf("%w: no ext", ErrTranscode)
+	}
+	if !strings.HasPrefix(p.Ext, ".") || p.Ext == "." || strings.ContainsAny(p.Ext, `/\`) {
+		return fmt.Errorf("%w: ext should be like .mp3: %s", ErrTranscode, p.Ext)
+	}
+	if len(p.Args) == 0 {
+		return fmt.Errorf("%w: no args", ErrTranscode)
+	}
+	return nil
+}
+
+// Transcoder converts a media file.
+type Transcoder interface {
+	// Transcode converts input into output with the preset and copies the tags.
+	Transcode(ctx context.Context, input, output string, preset Preset) error
+}
+
+var (
+	_ Transcoder = &FFTranscoder{}
+)
+
+var (
+	ErrTranscode = errors.New("Transcode")
+)
+
+// FFTranscoder converts file using ffmpeg.
+type FFTranscoder struct {
+	cmd string
+}
+
+func NewTranscoder(cmd string) *FFTranscoder {
+	return &FFTranscoder{
+		cmd: cmd,
+	}
+}
+
+func (t FFTranscoder) arguments(input, output string, preset Preset) []string {
+	r := []string{
+		"-v", "error", // log level
+		"-hide_banner",
+		"-nostdin",
+		"-nostats",
+		"-y",
+		"-i", input,
+		"-map_metadata", "0", // copy tags
+	}
+	r = append(r, preset.Args...)
+	return append(r, output)
+}
+
+func (t FFTranscoder) Transcode(ctx context.Context, input, output string, preset Preset) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.cmd, t.arguments(input, output, preset)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &Error{
+			Err:    fmt.Errorf("%w: path %s: %w", ErrTranscode, input, err),
+			Stderr: stderr.String(),
+		}
+	}
+	return nil
+}
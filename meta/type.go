@@ -0,0 +1,97 @@
+package meta
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueType is the type that a metadata value can be interpreted as.
+type ValueType int
+
+const (
+	StringType ValueType = iota
+	IntType
+	FloatType
+	DurationType
+	DateType
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case IntType:
+		return "int"
+	case FloatType:
+		return "float"
+	case DurationType:
+		return "duration"
+	case DateType:
+		return "date"
+	default:
+		return "string"
+	}
+}
+
+// ValueTypes is a set of ValueType.
+type ValueTypes uint8
+
+// AllValueTypes contains all the types.
+const AllValueTypes ValueTypes = 1<<IntType | 1<<FloatType | 1<<DurationType | 1<<DateType | 1<<StringType
+
+func (t ValueTypes) Contains(x ValueType) bool { return t&(1<<x) != 0 }
+
+// Narrowest returns the narrowest type in the set,
+// in the order of int, float, duration, date and string.
+func (t ValueTypes) Narrowest() ValueType {
+	for _, x := range []ValueType{IntType, FloatType, DurationType, DateType} {
+		if t.Contains(x) {
+			return x
+		}
+	}
+	return StringType
+}
+
+var (
+	clockDurationRegexp = regexp.MustCompile(`^(\d+:)?\d{1,2}:\d{2}(\.\d+)?$`)
+	dateLayouts         = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006/01/02",
+		"2006",
+	}
+)
+
+// TypesOf returns the types that the value can be interpreted as.
+func TypesOf(v string) ValueTypes {
+	r := ValueTypes(1 << StringType)
+	if v == "" {
+		return r
+	}
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		r |= 1<<IntType | 1<<FloatType
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil && strings.ContainsAny(v, "0123456789") {
+		r |= 1 << FloatType
+	}
+	if _, err := time.ParseDuration(v); err == nil || clockDurationRegexp.MatchString(v) {
+		r |= 1 << DurationType
+	}
+	if IsDate(v) {
+		r |= 1 << DateType
+	}
+	return r
+}
+
+// IsDate returns true if the value is a date, e.g. 2006, 2006-01-02, 2006-01-02T15:04:05Z07:00.
+func IsDate(v string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
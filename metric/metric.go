@@ -1,6 +1,9 @@
 package metric
 
-import "sync/atomic"
+import (
+	"sync/atomic"
+	"time"
+)
 
 func Incr(addr *uint64) {
 	atomic.AddUint64(addr, 1)
@@ -8,48 +11,92 @@ func Incr(addr *uint64) {
 
 var (
 	entryCount             uint64
+	dirCount               uint64
+	walkDuration           int64
 	probeCount             uint64
 	probeSuccessCount      uint64
 	probeFailedCount       uint64
+	probeRetryCount        uint64
+	probeTimeoutCount      uint64
 	selectCount            uint64
 	selectSuccessCount     uint64
 	selectFailedCount      uint64
 	selectDataMissingCount uint64
+	selectErrorCount       uint64
 	acceptCount            uint64
+	checkCount             uint64
+	checkSuccessCount      uint64
+	checkFailedCount       uint64
 )
 
 func IncrEntryCount()             { Incr(&entryCount) }
+func IncrDirCount()               { Incr(&dirCount) }
 func IncrProbeCount()             { Incr(&probeCount) }
 func IncrProbeSuccessCount()      { Incr(&probeSuccessCount) }
 func IncrProbeFailedCount()       { Incr(&probeFailedCount) }
+func IncrProbeRetryCount()        { Incr(&probeRetryCount) }
+func IncrProbeTimeoutCount()      { Incr(&probeTimeoutCount) }
 func IncrSelectCount()            { Incr(&selectCount) }
 func IncrSelectSuccessCount()     { Incr(&selectSuccessCount) }
 func IncrSelectFailedCount()      { Incr(&selectFailedCount) }
 func IncrSelectDataMissingCount() { Incr(&selectDataMissingCount) }
+func IncrSelectErrorCount()       { Incr(&selectErrorCount) }
 func IncrAcceptCount()            { Incr(&acceptCount) }
+func IncrCheckCount()             { Incr(&checkCount) }
+func IncrCheckSuccessCount()      { Incr(&checkSuccessCount) }
+func IncrCheckFailedCount()       { Incr(&checkFailedCount) }
+
+// SetWalkDuration records the time taken to walk all roots,
+// excluding the time waiting for the probes to receive the entries.
+func SetWalkDuration(d time.Duration) { atomic.StoreInt64(&walkDuration, int64(d)) }
 
 type Metrics struct {
 	EntryCount             uint64
+	DirCount               uint64
+	WalkDuration           float64 // seconds, excluding the backpressure of the probes
+	WalkThroughput         float64 // entries per second of the walk only, not of the pipeline
 	ProbeCount             uint64
 	ProbeSuccessCount      uint64
 	ProbeFailedCount       uint64
+	ProbeRetryCount        uint64
+	ProbeTimeoutCount      uint64
 	SelectCount            uint64
 	SelectSuccessCount     uint64
 	SelectFailedCount      uint64
 	SelectDataMissingCount uint64
+	SelectErrorCount       uint64 // e.g. timeouts of the scripts, not the mismatches
 	AcceptCount            uint64
+	CheckCount             uint64
+	CheckSuccessCount      uint64
+	CheckFailedCount       uint64
 }
 
 func Get() *Metrics {
+	var (
+		walkSeconds = time.Duration(atomic.LoadInt64(&walkDuration)).Seconds()
+		throughput  float64
+	)
+	if walkSeconds > 0 {
+		throughput = float64(atomic.LoadUint64(&entryCount)) / walkSeconds
+	}
 	return &Metrics{
 		EntryCount:             entryCount,
+		DirCount:               dirCount,
+		WalkDuration:           walkSeconds,
+		WalkThroughput:         throughput,
 		ProbeCount:             probeCount,
 		ProbeSuccessCount:      probeSuccessCount,
 		ProbeFailedCount:       probeFailedCount,
+		ProbeRetryCount:        probeRetryCount,
+		ProbeTimeoutCount:      probeTimeoutCount,
 		SelectCount:            selectCount,
 		SelectSuccessCount:     selectSuccessCount,
 		SelectFailedCount:      selectFailedCount,
 		SelectDataMissingCount: selectDataMissingCount,
+		SelectErrorCount:       selectErrorCount,
 		AcceptCount:            acceptCount,
+		CheckCount:             checkCount,
+		CheckSuccessCount:      checkSuccessCount,
+		CheckFailedCount:       checkFailedCount,
 	}
 }
@@ -0,0 +1,258 @@
+// Package organize moves, copies or links files into a layout.
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/iox"
+	"github.com/berquerant/fflist/layout"
+)
+
+var (
+	ErrOrganize = errors.New("Organize")
+)
+
+// Mode is how to place the files.
+type Mode string
+
+const (
+	Move     Mode = "move"
+	Copy     Mode = "copy"
+	Hardlink Mode = "hardlink"
+	Symlink  Mode = "symlink"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch x := Mode(s); x {
+	case Move, Copy, Hardlink, Symlink:
+		return x, nil
+	default:
+		return "", fmt.Errorf("%w: unknown mode %s", ErrOrganize, s)
+	}
+}
+
+// Policy is what to do when the destination already exists.
+type Policy string
+
+const (
+	// Skip skips the file.
+	Skip Policy = "skip"
+	// Overwrite replaces the existing file, the replaced file cannot be restored by undo.
+	Overwrite Policy = "overwrite"
+	// Rename appends a number to the name, e.g. name (1).mp3.
+	Rename Policy = "rename"
+	// Fail reports an error.
+	Fail Policy = "error"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch x := Policy(s); x {
+	case Skip, Overwrite, Rename, Fail:
+		return x, nil
+	default:
+		return "", fmt.Errorf("%w: unknown collision policy %s", ErrOrganize, s)
+	}
+}
+
+// Op is an operation of the plan.
+type Op struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest,omitempty"`
+	Mode   Mode   `json:"mode"`
+	// Skip is the reason why the operation is skipped.
+	Skip string `json:"skip,omitempty"`
+	// Overwrite is true if the operation replaces the existing file.
+	Overwrite bool   `json:"overwrite,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Runnable returns true if the operation should be executed.
+func (op Op) Runnable() bool { return op.Skip == "" && op.Error == "" }
+
+// Planner plans the operations.
+type Planner struct {
+	tmpl    *layout.Template
+	dest    string
+	mode    Mode
+	policy  Policy
+	planned map[string]bool
+}
+
+func NewPlanner(tmpl *layout.Template, dest string, mode Mode, policy Policy) *Planner {
+	return &Planner{
+		tmpl:    tmpl,
+		dest:    dest,
+		mode:    mode,
+		policy:  policy,
+		planned: map[string]bool{},
+	}
+}
+
+// Plan returns the operation for the file.
+// The source should be a regular file, e.g. not a directory of the group and not a member of the archive.
+func (p *Planner) Plan(data info.Getter) *Op {
+	path, _ := data.Get("path")
+	op := &Op{
+		Source: path,
+		Mode:   p.mode,
+	}
+	if err := checkRegular(path); err != nil {
+		op.Error = err.Error()
+		return op
+	}
+	rel, err := p.tmpl.Render(data)
+	if err != nil {
+		op.Error = err.Error()
+		return op
+	}
+	op.Dest = filepath.Join(p.dest, rel)
+	if same, _ := samePath(op.Source, op.Dest); same {
+		op.Skip = "same path"
+		return op
+	}
+
+	if p.exists(op.Dest) {
+		switch p.policy {
+		case Skip:
+			op.Skip = "exists"
+			return op
+		case Fail:
+			op.Error = fmt.Sprintf("%s: exists", op.Dest)
+			return op
+		case Rename:
+			op.Dest = p.rename(op.Dest)
+		case Overwrite:
+			if p.planned[op.Dest] {
+				// do not overwrite the file placed by this plan
+				op.Skip = "duplicated"
+				return op
+			}
+			op.Overwrite = true
+		}
+	}
+	p.planned[op.Dest] = true
+	return op
+}
+
+func (p *Planner) exists(path string) bool {
+	if p.planned[path] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (p *Planner) rename(path string) string {
+	var (
+		ext  = filepath.Ext(path)
+		base = strings.TrimSuffix(path, ext)
+	)
+	for i := 1; ; i++ {
+		x := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !p.exists(x) {
+			return x
+		}
+	}
+}
+
+func checkRegular(path string) error {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s: not a regular file", ErrOrganize, path)
+	}
+	return nil
+}
+
+func samePath(a, b string) (bool, error) {
+	x, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return x == y, nil
+}
+
+// Execute runs the operation.
+func Execute(op *Op) error {
+	if !op.Runnable() {
+		return nil
+	}
+	if err := checkRegular(op.Source); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(op.Dest), 0755); err != nil {
+		return err
+	}
+	if op.Overwrite && op.Mode != Move && op.Mode != Copy {
+		// link fails if the destination exists
+		if err := os.Remove(op.Dest); err != nil {
+			return err
+		}
+	}
+
+	switch op.Mode {
+	case Move:
+		return move(op.Source, op.Dest)
+	case Copy:
+		return iox.CopyFile(op.Source, op.Dest)
+	case Hardlink:
+		return os.Link(op.Source, op.Dest)
+	case Symlink:
+		src, err := filepath.Abs(op.Source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(src, op.Dest)
+	default:
+		return fmt.Errorf("%w: unknown mode %s", ErrOrganize, op.Mode)
+	}
+}
+
+// Undo reverts the executed operation.
+func Undo(op *Op) error {
+	if !op.Runnable() {
+		return nil
+	}
+	switch op.Mode {
+	case Move:
+		if _, err := os.Lstat(op.Source); err == nil {
+			return fmt.Errorf("%w: %s exists", ErrOrganize, op.Source)
+		}
+		if err := os.MkdirAll(filepath.Dir(op.Source), 0755); err != nil {
+			return err
+		}
+		return move(op.Dest, op.Source)
+	default:
+		if op.Overwrite {
+			return fmt.Errorf("%w: %s was overwritten and cannot be restored", ErrOrganize, op.Dest)
+		}
+		return os.Remove(op.Dest)
+	}
+}
+
+func move(src, dest string) error {
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	// across filesystems
+	if err := iox.CopyFile(src, dest); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
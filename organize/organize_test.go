@@ -0,0 +1,162 @@
+package organize_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/layout"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/organize"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganize(t *testing.T) {
+	tmpl, err := layout.New(`{{.artist}}/{{.title}}{{.ext}}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setup := func(t *testing.T) (string, string, []info.Getter) {
+		var (
+			src  = t.TempDir()
+			dest = t.TempDir()
+			r    []info.Getter
+		)
+		for name, tags := range map[string]map[string]string{
+			"a.mp3": {"artist": "X", "title": "T"},
+			"b.mp3": {"artist": "X", "title": "T"},
+		} {
+			path := filepath.Join(src, name)
+			if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+				t.Fatal(err)
+			}
+			tags["path"] = path
+			tags["ext"] = ".mp3"
+			r = append(r, info.New(meta.NewData(tags)))
+		}
+		if err := os.MkdirAll(filepath.Join(dest, "Y"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dest, "Y", "T.mp3"), []byte("existing"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return src, dest, r
+	}
+	read := func(t *testing.T, path string) string {
+		b, err := os.ReadFile(path)
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		return string(b)
+	}
+
+	for _, mode := range []organize.Mode{organize.Move, organize.Copy, organize.Hardlink, organize.Symlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			src, dest, files := setup(t)
+			planner := organize.NewPlanner(tmpl, dest, mode, organize.Rename)
+
+			var ops []*organize.Op
+			for _, f := range files {
+				op := planner.Plan(f)
+				ops = append(ops, op)
+				if !assert.Nil(t, organize.Execute(op)) {
+					return
+				}
+			}
+			// map iteration order
+			if filepath.Base(ops[0].Source) != "a.mp3" {
+				ops[0], ops[1] = ops[1], ops[0]
+			}
+			assert.Equal(t, "a.mp3", read(t, ops[0].Dest))
+			assert.Equal(t, "b.mp3", read(t, ops[1].Dest))
+			assert.ElementsMatch(t, []string{
+				filepath.Join(dest, "X", "T.mp3"),
+				filepath.Join(dest, "X", "T (1).mp3"),
+			}, []string{ops[0].Dest, ops[1].Dest})
+			_, err := os.Stat(filepath.Join(src, "a.mp3"))
+			assert.Equal(t, mode != organize.Move, err == nil, "source exists")
+
+			for i := len(ops) - 1; i >= 0; i-- {
+				if !assert.Nil(t, organize.Undo(ops[i])) {
+					return
+				}
+			}
+			assert.Equal(t, "a.mp3", read(t, filepath.Join(src, "a.mp3")))
+			assert.Equal(t, "b.mp3", read(t, filepath.Join(src, "b.mp3")))
+			entries, err := os.ReadDir(filepath.Join(dest, "X"))
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(entries))
+		})
+	}
+
+	t.Run("not regular", func(t *testing.T) {
+		src, dest, _ := setup(t)
+		if err := os.Mkdir(filepath.Join(src, "album"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(src, "a.mp3"), filepath.Join(src, "link.mp3")); err != nil {
+			t.Fatal(err)
+		}
+		planner := organize.NewPlanner(tmpl, dest, organize.Move, organize.Rename)
+		for _, name := range []string{"album", "link.mp3", "a.zip!/a.mp3"} {
+			path := filepath.Join(src, name)
+			op := planner.Plan(info.New(meta.NewData(map[string]string{
+				"path":   path,
+				"artist": "X",
+				"title":  "T",
+				"ext":    ".mp3",
+			})))
+			assert.NotEqual(t, "", op.Error, name)
+			assert.NotNil(t, organize.Execute(&organize.Op{
+				Source: path,
+				Dest:   filepath.Join(dest, "moved"),
+				Mode:   organize.Move,
+			}), name)
+			_, err := os.Lstat(path)
+			assert.Equal(t, name != "a.zip!/a.mp3", err == nil, "source exists: %s", name)
+		}
+		_, err := os.Lstat(filepath.Join(dest, "moved"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		tmpl, err := layout.New(`Y/{{.title}}{{.ext}}`)
+		if !assert.Nil(t, err) {
+			return
+		}
+		for _, tc := range []struct {
+			policy organize.Policy
+			check  func(t *testing.T, op *organize.Op)
+		}{
+			{
+				policy: organize.Skip,
+				check: func(t *testing.T, op *organize.Op) {
+					assert.Equal(t, "exists", op.Skip)
+				},
+			},
+			{
+				policy: organize.Fail,
+				check: func(t *testing.T, op *organize.Op) {
+					assert.NotEqual(t, "", op.Error)
+				},
+			},
+			{
+				policy: organize.Overwrite,
+				check: func(t *testing.T, op *organize.Op) {
+					assert.True(t, op.Overwrite)
+					assert.Nil(t, organize.Execute(op))
+					assert.Equal(t, filepath.Base(op.Source), read(t, op.Dest))
+					assert.NotNil(t, organize.Undo(op), "cannot restore")
+				},
+			},
+		} {
+			t.Run(string(tc.policy), func(t *testing.T) {
+				_, dest, files := setup(t)
+				planner := organize.NewPlanner(tmpl, dest, organize.Copy, tc.policy)
+				tc.check(t, planner.Plan(files[0]))
+			})
+		}
+	})
+}
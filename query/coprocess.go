@@ -0,0 +1,288 @@
+package query
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/metric"
+)
+
+var (
+	_ Selector  = &CoprocessSelector{}
+	_ Explainer = &CoprocessSelector{}
+	_ io.Closer = &CoprocessSelector{}
+)
+
+var (
+	ErrCoprocess = errors.New("Coprocess")
+
+	errCoprocessExited = fmt.Errorf("%w: exited", ErrCoprocess)
+)
+
+// CoprocessSelector sends the metadata to long-lived script processes.
+//
+// The script is started once per concurrent Select and receives the metadata in jsonl format from stdin.
+// The processes are reused by the following Select, so that the number of the processes is
+// the max number of the concurrent Select.
+// The script should write one line per record to stdout,
+// "true" or "0" to select the file, otherwise the file is not selected.
+// The script is restarted if it crashes or does not respond within the timeout.
+type CoprocessSelector struct {
+	shell   string
+	script  string
+	timeout time.Duration
+
+	mux  sync.Mutex
+	idle []*coprocess
+}
+
+// NewCoprocessSelector returns a new CoprocessSelector.
+// The script is run by sh.
+func NewCoprocessSelector(q Query, timeout time.Duration) *CoprocessSelector {
+	return &CoprocessSelector{
+		shell:   "sh",
+		script:  q.Value(),
+		timeout: timeout,
+	}
+}
+
+func (s *CoprocessSelector) Select(ctx context.Context, data info.Getter) bool {
+	metric.IncrSelectCount()
+
+	line, err := s.request(ctx, data)
+	r := err == nil && isTrueLine(line)
+	slog.Debug("CoprocessSelector", slog.String("line", line), slog.Bool("result", r), logx.Err(err))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		metric.IncrSelectErrorCount()
+		slog.Warn("CoprocessSelector", logx.Err(err))
+	}
+
+	if r {
+		metric.IncrSelectSuccessCount()
+	} else {
+		metric.IncrSelectFailedCount()
+	}
+	return r
+}
+
+func (s *CoprocessSelector) Explain(ctx context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:  "coprocess",
+		Key:   "shco",
+		Query: s.script,
+	}
+	if data == nil {
+		return t
+	}
+	line, err := s.request(ctx, data)
+	if err == nil {
+		t.Value = &line
+	}
+	return t.setErr(err).setResult(err == nil && isTrueLine(line))
+}
+
+func isTrueLine(line string) bool {
+	switch strings.TrimSpace(line) {
+	case "true", "0":
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *CoprocessSelector) request(ctx context.Context, data info.Getter) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	line, err := s.requestOnce(ctx, b)
+	if errors.Is(err, errCoprocessExited) {
+		// the script crashed, retry with a new process
+		line, err = s.requestOnce(ctx, b)
+	}
+	return line, err
+}
+
+func (s *CoprocessSelector) requestOnce(ctx context.Context, b []byte) (string, error) {
+	p, err := s.acquire()
+	if err != nil {
+		return "", err
+	}
+
+	line, err := p.request(ctx, append(b, '\n'), s.timeout)
+	if err != nil {
+		// restart on the next request, the output may be out of sync
+		p.kill()
+		return line, err
+	}
+	s.release(p)
+	return line, nil
+}
+
+// acquire returns an idle process or starts a new process.
+func (s *CoprocessSelector) acquire() (*coprocess, error) {
+	s.mux.Lock()
+	for len(s.idle) > 0 {
+		p := s.idle[len(s.idle)-1]
+		s.idle = s.idle[:len(s.idle)-1]
+		if !p.exited() {
+			s.mux.Unlock()
+			return p, nil
+		}
+	}
+	s.mux.Unlock()
+	return startCoprocess(s.shell, s.script)
+}
+
+func (s *CoprocessSelector) release(p *coprocess) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.idle = append(s.idle, p)
+}
+
+// Close stops the scripts.
+// Close should be called after all Select are finished.
+func (s *CoprocessSelector) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var errs []error
+	for _, p := range s.idle {
+		errs = append(errs, p.close(s.timeout))
+	}
+	s.idle = nil
+	return errors.Join(errs...)
+}
+
+type coprocess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	done  chan struct{} // closed when the process exited
+	quit  chan struct{} // closed when killed
+	once  sync.Once
+}
+
+func startCoprocess(shell, script string) (*coprocess, error) {
+	cmd := exec.Command(shell, "-c", script)
+	cmd.Env = os.Environ()
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Join(ErrCoprocess, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Join(ErrCoprocess, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Join(ErrCoprocess, err)
+	}
+	slog.Debug("CoprocessSelector start", slog.Int("pid", cmd.Process.Pid))
+
+	p := &coprocess{
+		cmd:   cmd,
+		stdin: stdin,
+		lines: make(chan string, 1),
+		done:  make(chan struct{}),
+		quit:  make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case <-p.quit:
+				_, _ = io.Copy(io.Discard, stdout)
+			case p.lines <- scanner.Text():
+			}
+		}
+		err := cmd.Wait()
+		slog.Debug("CoprocessSelector exit", slog.Int("pid", cmd.Process.Pid), logx.Err(err))
+	}()
+	return p, nil
+}
+
+func (p *coprocess) request(ctx context.Context, b []byte, timeout time.Duration) (string, error) {
+	writeErrC := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(b)
+		writeErrC <- err
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	for {
+		select {
+		case err := <-writeErrC:
+			if err != nil {
+				return "", errors.Join(ErrCoprocess, err)
+			}
+			writeErrC = nil
+		case line := <-p.lines:
+			return line, nil
+		case <-p.done:
+			select {
+			case line := <-p.lines:
+				return line, nil
+			default:
+				return "", errCoprocessExited
+			}
+		case <-timeoutC:
+			return "", fmt.Errorf("%w: timeout %s", ErrCoprocess, timeout)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (p *coprocess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill kills the process without waiting for the exit
+// because the descendants of the script may keep stdout open.
+func (p *coprocess) kill() {
+	p.once.Do(func() {
+		close(p.quit)
+		_ = p.stdin.Close()
+		_ = p.cmd.Process.Kill()
+	})
+}
+
+// close closes stdin and waits for the process to exit.
+func (p *coprocess) close(timeout time.Duration) error {
+	_ = p.stdin.Close()
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(timeout):
+		p.kill()
+		return fmt.Errorf("%w: killed", ErrCoprocess)
+	}
+}
@@ -0,0 +1,99 @@
+package query_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoprocessSelector(t *testing.T) {
+	newData := func(value string) info.Getter {
+		return info.New(meta.NewData(map[string]string{
+			"value": value,
+		}))
+	}
+
+	t.Run("select", func(t *testing.T) {
+		// echo true for ref, false otherwise
+		s := query.NewCoprocessSelector(
+			query.NewQuery("shco", `while read -r line ; do case "$line" in *'"ref"'*) echo true ;; *) echo false ;; esac ; done`),
+			5*time.Second,
+		)
+		defer s.Close()
+		for _, tc := range []struct {
+			value string
+			want  bool
+		}{
+			{value: "ref", want: true},
+			{value: "fer", want: false},
+			{value: "ref", want: true},
+		} {
+			assert.Equal(t, tc.want, s.Select(context.TODO(), newData(tc.value)), tc.value)
+		}
+		assert.Nil(t, s.Close())
+	})
+
+	t.Run("exit status style", func(t *testing.T) {
+		s := query.NewCoprocessSelector(
+			query.NewQuery("shco", `while read -r line ; do echo 0 ; done`),
+			5*time.Second,
+		)
+		defer s.Close()
+		assert.True(t, s.Select(context.TODO(), newData("ref")))
+	})
+
+	t.Run("restart on crash", func(t *testing.T) {
+		// answer once and exit
+		s := query.NewCoprocessSelector(
+			query.NewQuery("shco", `read -r line ; echo true`),
+			5*time.Second,
+		)
+		defer s.Close()
+		assert.True(t, s.Select(context.TODO(), newData("ref")))
+		assert.True(t, s.Select(context.TODO(), newData("ref")))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		s := query.NewCoprocessSelector(
+			query.NewQuery("shco", `while read -r line ; do case "$line" in *'"slow"'*) sleep 2 ;; esac ; echo true ; done`),
+			200*time.Millisecond,
+		)
+		defer s.Close()
+		assert.False(t, s.Select(context.TODO(), newData("slow")))
+		assert.True(t, s.Select(context.TODO(), newData("ref")))
+	})
+}
+
+func TestCoprocessSelectorConcurrency(t *testing.T) {
+	const n = 4
+	s := query.NewCoprocessSelector(
+		query.NewQuery("shco", `while read -r line ; do sleep 0.5 ; echo true ; done`),
+		5*time.Second,
+	)
+	defer s.Close()
+	data := info.New(meta.NewData(map[string]string{}))
+
+	for i := range 2 {
+		var (
+			wg        sync.WaitGroup
+			startTime = time.Now()
+		)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.True(t, s.Select(context.TODO(), data))
+			}()
+		}
+		wg.Wait()
+		// serialized requests take 2s
+		assert.Less(t, time.Since(startTime), 1500*time.Millisecond, "round %d", i)
+	}
+	assert.Nil(t, s.Close())
+}
@@ -0,0 +1,70 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/berquerant/fflist/info"
+)
+
+// Trace is the explanation of a selector.
+type Trace struct {
+	// Type is the type of the selector, e.g. and, or, regexp.
+	Type string `json:"type"`
+	// Key is the key of the query.
+	Key string `json:"key,omitempty"`
+	// Query is the value of the query, e.g. the regular expression.
+	Query string `json:"query,omitempty"`
+	// Value is the actual value of the key.
+	Value *string `json:"value,omitempty"`
+	// Found is true if the key exists.
+	Found *bool `json:"found,omitempty"`
+	// Result is the result of the selector.
+	Result *bool  `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Children are the traces of the nested selectors.
+	Children []*Trace `json:"children,omitempty"`
+}
+
+func (t *Trace) setResult(r bool) *Trace {
+	t.Result = &r
+	return t
+}
+
+func (t *Trace) setErr(err error) *Trace {
+	if err != nil {
+		t.Error = err.Error()
+	}
+	return t
+}
+
+// Explainer is a selector that can explain itself.
+type Explainer interface {
+	// Explain returns the selector tree if data is nil,
+	// otherwise evaluates data and returns the trace.
+	// Unlike Select, all the nested selectors are evaluated.
+	Explain(ctx context.Context, data info.Getter) *Trace
+}
+
+// Explain returns the trace of the selector.
+// See Explainer.
+func Explain(ctx context.Context, s Selector, data info.Getter) *Trace {
+	if x, ok := s.(Explainer); ok {
+		return x.Explain(ctx, data)
+	}
+	t := &Trace{
+		Type: fmt.Sprintf("%T", s),
+	}
+	if data == nil {
+		return t
+	}
+	return t.setResult(s.Select(ctx, data))
+}
+
+func explainAll(ctx context.Context, selectors []Selector, data info.Getter) []*Trace {
+	r := make([]*Trace, len(selectors))
+	for i, x := range selectors {
+		r[i] = Explain(ctx, x, data)
+	}
+	return r
+}
@@ -0,0 +1,59 @@
+package query_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	newRegexp := func(key, value string) query.Selector {
+		s, err := query.NewRegexpSelector(query.NewQuery(key, value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	selector := query.NewOrSelector(
+		query.NewAndSelector(newRegexp("name", "a")),
+		query.NewAndSelector(newRegexp("artist", "A1"), newRegexp("ext", "mp3")),
+	)
+
+	for _, tc := range []struct {
+		title string
+		data  info.Getter
+		want  string
+	}{
+		{
+			title: "tree",
+			want: `{"type":"or","children":[
+{"type":"and","children":[{"type":"regexp","key":"name","query":"a"}]},
+{"type":"and","children":[{"type":"regexp","key":"artist","query":"A1"},{"type":"regexp","key":"ext","query":"mp3"}]}]}`,
+		},
+		{
+			title: "trace",
+			data: info.New(meta.NewData(map[string]string{
+				"name": "b.mp3",
+				"ext":  ".mp3",
+			})),
+			want: `{"type":"or","result":false,"children":[
+{"type":"and","result":false,"children":[{"type":"regexp","key":"name","query":"a","value":"b.mp3","found":true,"result":false}]},
+{"type":"and","result":false,"children":[
+{"type":"regexp","key":"artist","query":"A1","found":false,"result":false},
+{"type":"regexp","key":"ext","query":"mp3","value":".mp3","found":true,"result":true}]}]}`,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := json.Marshal(query.Explain(context.TODO(), selector, tc.data))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.JSONEq(t, tc.want, string(got))
+		})
+	}
+}
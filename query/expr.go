@@ -0,0 +1,135 @@
+package query
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/metric"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+var (
+	_ Selector  = &ExprSelector{}
+	_ Explainer = &ExprSelector{}
+)
+
+// ExprSelector evaluates an expression over the metadata in process.
+//
+// The keys of the metadata are available as variables.
+// The values are int or float if they are numbers, time if they are times (RFC3339 or 2006-01-02 15:04:05),
+// otherwise string.
+// The keys that are not valid identifiers (e.g. streams.0.codec_name) or
+// conflict with the builtin functions (e.g. duration) are available by $env["KEY"].
+// Missing keys are nil.
+// See https://expr-lang.org/docs/language-definition for the syntax and the builtin functions,
+// e.g. size > 8000000, ext in [".mp3", ".flac"], title matches "^A",
+// now() - mod_time_rfc3339 < duration("24h").
+type ExprSelector struct {
+	program *vm.Program
+}
+
+func NewExprSelector(q Query) (*ExprSelector, error) {
+	p, err := expr.Compile(
+		q.Value(),
+		expr.AsBool(),
+		expr.AllowUndefinedVariables(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ExprSelector{
+		program: p,
+	}, nil
+}
+
+func (s ExprSelector) Select(_ context.Context, data info.Getter) bool {
+	metric.IncrSelectCount()
+
+	r, err := s.run(data)
+	slog.Debug("ExprSelector", slog.String("expr", s.program.Source().String()), slog.Bool("result", r), logx.Err(err))
+	if err != nil {
+		metric.IncrSelectErrorCount()
+		path, _ := data.Get("path")
+		slog.Warn("ExprSelector", slog.String("expr", s.program.Source().String()), slog.String("path", path), logx.Err(err))
+	}
+
+	if r {
+		metric.IncrSelectSuccessCount()
+	} else {
+		metric.IncrSelectFailedCount()
+	}
+	return r
+}
+
+func (s ExprSelector) Explain(_ context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:  "expr",
+		Key:   "expr",
+		Query: s.program.Source().String(),
+	}
+	if data == nil {
+		return t
+	}
+	r, err := s.run(data)
+	return t.setErr(err).setResult(r)
+}
+
+func (s ExprSelector) run(data info.Getter) (bool, error) {
+	env, err := newExprEnv(data)
+	if err != nil {
+		return false, err
+	}
+	v, err := expr.Run(s.program, env)
+	if err != nil {
+		return false, err
+	}
+	r, _ := v.(bool)
+	return r, nil
+}
+
+func newExprEnv(data info.Getter) (map[string]any, error) {
+	all, ok := data.(interface {
+		All() iter.Seq2[string, string]
+	})
+	if !ok {
+		m, err := info.ToMap(data)
+		if err != nil {
+			return nil, err
+		}
+		all = meta.NewData(m)
+	}
+
+	env := map[string]any{}
+	for k, v := range all.All() {
+		env[k] = exprValue(v)
+	}
+	return env, nil
+}
+
+// exprValue converts the value into int, float64 or time.Time if possible.
+func exprValue(v string) any {
+	if x, err := strconv.Atoi(v); err == nil {
+		return x
+	}
+	// exclude inf and nan
+	if x, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(x, 0) && !math.IsNaN(x) {
+		return x
+	}
+	if len(v) >= len(time.DateTime) && v[4] == '-' {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+		if t, err := time.ParseInLocation(time.DateTime, v, time.Local); err == nil {
+			return t
+		}
+	}
+	return v
+}
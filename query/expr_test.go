@@ -0,0 +1,127 @@
+package query_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprSelector(t *testing.T) {
+	data := info.New(meta.NewData(map[string]string{
+		"name":                 "track.flac",
+		"ext":                  ".flac",
+		"size":                 "9000000",
+		"duration":             "215.5",
+		"mod_time_rfc3339":     "2024-05-01T10:00:00Z",
+		"mod_time":             "2024-05-01 10:00:00",
+		"track":                "07",
+		"bit_rate":             "inf",
+		"title":                "Prologue",
+		"streams.0.codec_name": "flac",
+	}))
+
+	for _, tc := range []struct {
+		title string
+		expr  string
+		want  bool
+	}{
+		{
+			title: "int",
+			expr:  `int(size) > 8000000`,
+			want:  true,
+		},
+		{
+			title: "float arithmetic",
+			expr:  `float($env.duration) / 60 < 3`,
+			want:  false,
+		},
+		{
+			title: "in list",
+			expr:  `ext in [".mp3", ".flac"]`,
+			want:  true,
+		},
+		{
+			title: "string function",
+			expr:  `lower(title) startsWith "pro"`,
+			want:  true,
+		},
+		{
+			title: "regex",
+			expr:  `name matches "\\.mp3$"`,
+			want:  false,
+		},
+		{
+			title: "int value",
+			expr:  `size > 8000000 && track == 7`,
+			want:  true,
+		},
+		{
+			title: "float value",
+			expr:  `$env.duration / 60 > 3.5`,
+			want:  true,
+		},
+		{
+			title: "not a number",
+			expr:  `bit_rate == "inf"`,
+			want:  true,
+		},
+		{
+			title: "date math",
+			expr:  `mod_time_rfc3339 - date("2024-04-01T10:00:00Z") >= duration("720h")`,
+			want:  true,
+		},
+		{
+			title: "local time",
+			expr:  `mod_time == date("2024-05-01 10:00:00", "2006-01-02 15:04:05", "Local")`,
+			want:  true,
+		},
+		{
+			title: "key not an identifier",
+			expr:  `$env["streams.0.codec_name"] == "flac"`,
+			want:  true,
+		},
+		{
+			title: "missing key",
+			expr:  `artist == nil`,
+			want:  true,
+		},
+		{
+			title: "runtime error",
+			expr:  `int(title) > 0`,
+			want:  false,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s, err := query.NewExprSelector(query.NewQuery("expr", tc.expr))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, s.Select(context.TODO(), data))
+		})
+	}
+
+	t.Run("getter", func(t *testing.T) {
+		s, err := query.NewExprSelector(query.NewQuery("expr", `size > 8000000 && title == "Prologue"`))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, s.Select(context.TODO(), getter{data}))
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := query.NewExprSelector(query.NewQuery("expr", `size >`))
+		assert.NotNil(t, err)
+	})
+}
+
+// getter hides the methods of the metadata other than Get and MarshalJSON.
+type getter struct {
+	info.Getter
+}
+
+func (g getter) MarshalJSON() ([]byte, error) { return json.Marshal(g.Getter) }
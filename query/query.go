@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/berquerant/fflist/info"
@@ -30,8 +31,26 @@ type Selector interface {
 	Select(ctx context.Context, data info.Getter) bool
 }
 
+// Close closes the selector if it holds resources, e.g. processes.
+func Close(s Selector) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func closeAll(selectors []Selector) error {
+	errs := make([]error, len(selectors))
+	for i, x := range selectors {
+		errs[i] = Close(x)
+	}
+	return errors.Join(errs...)
+}
+
 var (
-	_ Selector = &AndSelector{}
+	_ Selector  = &AndSelector{}
+	_ Explainer = &AndSelector{}
+	_ io.Closer = &AndSelector{}
 )
 
 func NewAndSelector(selectors ...Selector) *AndSelector {
@@ -53,8 +72,27 @@ func (s AndSelector) Select(ctx context.Context, data info.Getter) bool {
 	return true
 }
 
+func (s AndSelector) Explain(ctx context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:     "and",
+		Children: explainAll(ctx, s.selectors, data),
+	}
+	if data == nil {
+		return t
+	}
+	r := true
+	for _, x := range t.Children {
+		r = r && x.Result != nil && *x.Result
+	}
+	return t.setResult(r)
+}
+
+func (s AndSelector) Close() error { return closeAll(s.selectors) }
+
 var (
-	_ Selector = &OrSelector{}
+	_ Selector  = &OrSelector{}
+	_ Explainer = &OrSelector{}
+	_ io.Closer = &OrSelector{}
 )
 
 func NewOrSelector(selectors ...Selector) *OrSelector {
@@ -76,8 +114,26 @@ func (s OrSelector) Select(ctx context.Context, data info.Getter) bool {
 	return false
 }
 
+func (s OrSelector) Explain(ctx context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:     "or",
+		Children: explainAll(ctx, s.selectors, data),
+	}
+	if data == nil {
+		return t
+	}
+	r := false
+	for _, x := range t.Children {
+		r = r || x.Result != nil && *x.Result
+	}
+	return t.setResult(r)
+}
+
+func (s OrSelector) Close() error { return closeAll(s.selectors) }
+
 var (
-	_ Selector = &TrueSelector{}
+	_ Selector  = &TrueSelector{}
+	_ Explainer = &TrueSelector{}
 )
 
 func NewTrueSelector() *TrueSelector {
@@ -91,3 +147,13 @@ func (TrueSelector) Select(_ context.Context, _ info.Getter) bool {
 	metric.IncrSelectSuccessCount()
 	return true
 }
+
+func (TrueSelector) Explain(_ context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type: "true",
+	}
+	if data == nil {
+		return t
+	}
+	return t.setResult(true)
+}
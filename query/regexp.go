@@ -10,7 +10,8 @@ import (
 )
 
 var (
-	_ Selector = &RegexpSelector{}
+	_ Selector  = &RegexpSelector{}
+	_ Explainer = &RegexpSelector{}
 )
 
 type RegexpSelector struct {
@@ -56,3 +57,21 @@ func (s RegexpSelector) Select(_ context.Context, data info.Getter) bool {
 	}
 	return r
 }
+
+func (s RegexpSelector) Explain(_ context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:  "regexp",
+		Key:   s.key,
+		Query: s.r.String(),
+	}
+	if data == nil {
+		return t
+	}
+	v, ok := data.Get(s.key)
+	t.Found = &ok
+	if !ok {
+		return t.setResult(false)
+	}
+	t.Value = &v
+	return t.setResult(s.r.MatchString(v))
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"os/exec"
 
 	"github.com/berquerant/execx"
 	"github.com/berquerant/fflist/info"
@@ -12,6 +14,11 @@ import (
 	"github.com/berquerant/fflist/metric"
 )
 
+var (
+	_ Selector  = &ScriptSelector{}
+	_ Explainer = &ScriptSelector{}
+)
+
 // ScriptSelector returns true if script exit with 0.
 // The stdin of script is the json of metadata.
 type ScriptSelector struct {
@@ -32,7 +39,24 @@ func NewScriptSelector(q Query) *ScriptSelector {
 func (s *ScriptSelector) Select(ctx context.Context, data info.Getter) bool {
 	metric.IncrSelectCount()
 
-	err := s.script.Runner(func(cmd *execx.Cmd) error {
+	err := s.run(ctx, data)
+	r := err == nil
+	slog.Debug("ScriptSelector", logx.Err(err))
+	if isScriptError(err) && ctx.Err() == nil {
+		metric.IncrSelectErrorCount()
+		slog.Warn("ScriptSelector", logx.Err(err))
+	}
+
+	if r {
+		metric.IncrSelectSuccessCount()
+	} else {
+		metric.IncrSelectFailedCount()
+	}
+	return r
+}
+
+func (s *ScriptSelector) run(ctx context.Context, data info.Getter) error {
+	return s.script.Runner(func(cmd *execx.Cmd) error {
 		b, err := json.Marshal(data)
 		if err != nil {
 			return err
@@ -41,14 +65,27 @@ func (s *ScriptSelector) Select(ctx context.Context, data info.Getter) bool {
 		_, err = cmd.Run(ctx)
 		return err
 	})
+}
 
-	r := err == nil
-	slog.Debug("ScriptSelector", logx.Err(err))
+// isScriptError returns true if the script failed to run or was killed,
+// not exited with non-zero status.
+func isScriptError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exitErr *exec.ExitError
+	return !errors.As(err, &exitErr) || exitErr.ExitCode() < 0
+}
 
-	if r {
-		metric.IncrSelectSuccessCount()
-	} else {
-		metric.IncrSelectFailedCount()
+func (s *ScriptSelector) Explain(ctx context.Context, data info.Getter) *Trace {
+	t := &Trace{
+		Type:  "script",
+		Key:   s.shell,
+		Query: s.script.Content,
 	}
-	return r
+	if data == nil {
+		return t
+	}
+	err := s.run(ctx, data)
+	return t.setErr(err).setResult(err == nil)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/berquerant/fflist/info"
 	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/metric"
 	"github.com/berquerant/fflist/query"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,7 @@ func TestScriptSelector(t *testing.T) {
 		q     query.Query
 		data  info.Getter
 		want  bool
+		err   bool
 	}{
 		{
 			title: "grep hit",
@@ -33,11 +35,20 @@ func TestScriptSelector(t *testing.T) {
 			})),
 			want: false,
 		},
+		{
+			title: "killed",
+			q:     query.NewQuery("sh", "kill -9 $$"),
+			data:  info.New(meta.NewData(map[string]string{})),
+			want:  false,
+			err:   true,
+		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
 			s := query.NewScriptSelector(tc.q)
+			errorCount := metric.Get().SelectErrorCount
 			got := s.Select(context.TODO(), tc.data)
 			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.err, metric.Get().SelectErrorCount > errorCount, "error")
 		})
 	}
 }
@@ -0,0 +1,64 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/worker"
+)
+
+// Check decodes the selected files and writes the results in jsonl.
+type Check struct {
+	source      Source
+	selector    query.Selector
+	checkWorker *worker.Checker
+	w           io.Writer
+	all         bool
+	verbose     bool
+}
+
+// NewCheck returns a new Check.
+// If all is false, only the files with problems are written.
+func NewCheck(
+	source Source,
+	selector query.Selector,
+	checkWorker *worker.Checker,
+	w io.Writer,
+	all bool,
+	verbose bool,
+) *Check {
+	return &Check{
+		source:      source,
+		selector:    selector,
+		checkWorker: checkWorker,
+		w:           w,
+		all:         all,
+		verbose:     verbose,
+	}
+}
+
+func (c *Check) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	dataC := Select(ctx, c.selector, c.source)
+	for r := range c.checkWorker.Start(ctx, dataC) {
+		if r.OK && !c.all {
+			continue
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			slog.Error("Failed to output", slog.String("path", r.Path), logx.Err(err))
+			continue
+		}
+		fmt.Fprintf(c.w, "%s\n", b)
+	}
+
+	writeMetrics(c.verbose, time.Since(startTime))
+	return c.source.Err()
+}
@@ -0,0 +1,99 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenChecker reports the files containing "bad" as broken.
+type brokenChecker struct{}
+
+func (brokenChecker) Check(_ context.Context, path string, expected time.Duration) (*meta.CheckResult, error) {
+	ok := !strings.Contains(path, "bad")
+	r := &meta.CheckResult{
+		Path:     path,
+		OK:       ok,
+		Duration: expected.Seconds(),
+	}
+	if !ok {
+		r.Errors = []string{"broken"}
+	}
+	return r, nil
+}
+
+func TestCheck(t *testing.T) {
+	fsys := fstest.MapFS{
+		"good.flac": &fstest.MapFile{},
+		"bad.flac":  &fstest.MapFile{},
+		"bad.mp3":   &fstest.MapFile{},
+	}
+	prober := meta.NewFixtureProber(map[string]map[string]string{
+		"good.flac": {"duration": "1.500000"},
+		"bad.flac":  {"duration": "2.000000"},
+		"bad.mp3":   {"duration": "3.000000"},
+	})
+	selector, err := query.NewRegexpSelector(query.NewQuery("ext", `\.flac$`))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, tc := range []struct {
+		title string
+		all   bool
+		want  []*meta.CheckResult
+	}{
+		{
+			title: "problems",
+			want: []*meta.CheckResult{
+				{Path: "bad.flac", Errors: []string{"broken"}, Duration: 2},
+			},
+		},
+		{
+			title: "all",
+			all:   true,
+			want: []*meta.CheckResult{
+				{Path: "bad.flac", Errors: []string{"broken"}, Duration: 2},
+				{Path: "good.flac", OK: true, Duration: 1.5},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			var (
+				out    bytes.Buffer
+				source = run.NewWalkSource(
+					[]string{"."},
+					worker.NewWalker(func() walk.Walker { return walk.NewFS(fsys) }),
+					worker.NewProbe(prober, 2),
+				)
+				c = run.NewCheck(source, selector, worker.NewCheck(brokenChecker{}, 2), &out, tc.all, false)
+			)
+			if !assert.Nil(t, c.Run(context.TODO())) {
+				return
+			}
+
+			got := []*meta.CheckResult{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				var r meta.CheckResult
+				if !assert.Nil(t, json.Unmarshal([]byte(line), &r)) {
+					return
+				}
+				got = append(got, &r)
+			}
+			slices.SortFunc(got, func(a, b *meta.CheckResult) int { return strings.Compare(a.Path, b.Path) })
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 
+	"github.com/berquerant/fflist/lint"
+	"github.com/berquerant/fflist/meta"
 	"github.com/berquerant/fflist/query"
 	"gopkg.in/yaml.v3"
 )
@@ -33,8 +36,26 @@ var (
 )
 
 type Config struct {
-	Root  []string   `json:"root" yaml:"root"`
-	Query [][]string `json:"query" yaml:"query"`
+	Root  []string    `json:"root" yaml:"root"`
+	Query [][]string  `json:"query" yaml:"query"`
+	Probe ProbeConfig `json:"probe" yaml:"probe"`
+	Lint  lint.Config `json:"lint" yaml:"lint"`
+	// Presets are the output options of the export by name.
+	Presets map[string]meta.Preset `json:"presets" yaml:"presets"`
+	// Queries are the named queries referenced by @name.
+	Queries map[string][]string `json:"queries" yaml:"queries"`
+}
+
+// ProbeConfig configures the arguments of ffprobe.
+type ProbeConfig struct {
+	// Sections are shown in addition to format, e.g. streams, chapters, programs.
+	Sections []string `json:"sections" yaml:"sections"`
+	// AnalyzeDuration is -analyzeduration.
+	AnalyzeDuration string `json:"analyzeduration" yaml:"analyzeduration"`
+	// ProbeSize is -probesize.
+	ProbeSize string `json:"probesize" yaml:"probesize"`
+	// Args are appended before the input.
+	Args []string `json:"args" yaml:"args"`
 }
 
 func (c Config) validate() error {
@@ -49,13 +70,37 @@ func (c Config) validate() error {
 			return fmt.Errorf("%w: empty query at index %d", ErrConfig, i)
 		}
 	}
+	return validatePresets(c.Presets)
+}
+
+// NamedQueries returns the named queries of the config merged with the queries.
+// The queries win over the config, and they can reference each other.
+func (c Config) NamedQueries(queries map[string][]string) (map[string][]string, error) {
+	r := map[string][]string{}
+	maps.Copy(r, c.Queries)
+	maps.Copy(r, queries)
+	if err := CheckNamedQueries(r); err != nil {
+		return nil, errors.Join(ErrConfig, err)
+	}
+	return r, nil
+}
+
+func validatePresets(presets map[string]meta.Preset) error {
+	for name, p := range presets {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%w: preset %s: %w", ErrConfig, name, err)
+		}
+	}
 	return nil
 }
 
-func (c Config) ParseQuery() (query.Selector, error) {
+// ParseQuery parses the query with the named queries of the config.
+// The named queries of the options win.
+func (c Config) ParseQuery(opt ...ParseOption) (query.Selector, error) {
+	opt = append([]ParseOption{WithNamedQueries(c.Queries)}, opt...)
 	r := make([]query.Selector, len(c.Query))
 	for i, a := range c.Query {
-		s, err := ParseQuery(a)
+		s, err := ParseQuery(a, opt...)
 		if err != nil {
 			return nil, fmt.Errorf("%w: index %d", err, i)
 		}
@@ -63,3 +108,52 @@ func (c Config) ParseQuery() (query.Selector, error) {
 	}
 	return query.NewOrSelector(r...), nil
 }
+
+// ParseLintConfig parses the rules of lint in json or yaml.
+func ParseLintConfig(r io.Reader) (*lint.Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var c lint.Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		if yErr := yaml.Unmarshal(b, &c); yErr != nil {
+			return nil, errors.Join(err, yErr)
+		}
+	}
+	return &c, nil
+}
+
+// ParsePresets parses the presets of export by name in json or yaml.
+func ParsePresets(r io.Reader) (map[string]meta.Preset, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var c map[string]meta.Preset
+	if err := json.Unmarshal(b, &c); err != nil {
+		if yErr := yaml.Unmarshal(b, &c); yErr != nil {
+			return nil, errors.Join(err, yErr)
+		}
+	}
+	if err := validatePresets(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseNamedQueries parses the named queries in json or yaml.
+// The references are not checked because they may be defined by the config, see CheckNamedQueries.
+func ParseNamedQueries(r io.Reader) (map[string][]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var c map[string][]string
+	if err := json.Unmarshal(b, &c); err != nil {
+		if yErr := yaml.Unmarshal(b, &c); yErr != nil {
+			return nil, errors.Join(err, yErr)
+		}
+	}
+	return c, nil
+}
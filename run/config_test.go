@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/berquerant/fflist/lint"
+	"github.com/berquerant/fflist/meta"
 	"github.com/berquerant/fflist/run"
 	"github.com/stretchr/testify/assert"
 )
@@ -45,6 +47,135 @@ query:
 				},
 			},
 		},
+		{
+			title: "probe",
+			src: `root:
+- ROOT
+query:
+- - name=NAME
+probe:
+  sections:
+  - streams
+  - chapter=title
+  analyzeduration: 10M
+  probesize: 5000000
+  args:
+  - -fflags
+  - +genpts`,
+			want: &run.Config{
+				Root: []string{
+					"ROOT",
+				},
+				Query: [][]string{
+					{"name=NAME"},
+				},
+				Probe: run.ProbeConfig{
+					Sections:        []string{"streams", "chapter=title"},
+					AnalyzeDuration: "10M",
+					ProbeSize:       "5000000",
+					Args:            []string{"-fflags", "+genpts"},
+				},
+			},
+		},
+		{
+			title: "lint",
+			src: `root:
+- ROOT
+query:
+- - name=NAME
+lint:
+  required:
+  - ext: [mp3]
+    keys: [artist]
+  format:
+  - key: date
+    type: date`,
+			want: &run.Config{
+				Root: []string{
+					"ROOT",
+				},
+				Query: [][]string{
+					{"name=NAME"},
+				},
+				Lint: lint.Config{
+					Required: []lint.RequiredRule{
+						{Ext: []string{"mp3"}, Keys: []string{"artist"}},
+					},
+					Format: []lint.FormatRule{
+						{Key: "date", Type: "date"},
+					},
+				},
+			},
+		},
+		{
+			title: "presets",
+			src: `root:
+- ROOT
+query:
+- - name=NAME
+presets:
+  mp3-320:
+    ext: .mp3
+    args: [-c:a, libmp3lame, -b:a, 320k]`,
+			want: &run.Config{
+				Root: []string{
+					"ROOT",
+				},
+				Query: [][]string{
+					{"name=NAME"},
+				},
+				Presets: map[string]meta.Preset{
+					"mp3-320": {
+						Ext:  ".mp3",
+						Args: []string{"-c:a", "libmp3lame", "-b:a", "320k"},
+					},
+				},
+			},
+		},
+		{
+			title: "preset ext without dot",
+			src: `root:
+- ROOT
+query:
+- - name=NAME
+presets:
+  mp3-320:
+    ext: mp3
+    args: [-c:a, libmp3lame, -b:a, 320k]`,
+			err: run.ErrConfig,
+		},
+		{
+			title: "preset without ext",
+			src: `root:
+- ROOT
+query:
+- - name=NAME
+presets:
+  mp3-320:
+    args: [-c:a, libmp3lame, -b:a, 320k]`,
+			err: run.ErrConfig,
+		},
+		{
+			title: "queries",
+			src: `root:
+- ROOT
+query:
+- - "@lossless"
+queries:
+  lossless:
+  - ext=flac`,
+			want: &run.Config{
+				Root: []string{
+					"ROOT",
+				},
+				Query: [][]string{
+					{"@lossless"},
+				},
+				Queries: map[string][]string{
+					"lossless": {"ext=flac"},
+				},
+			},
+		},
 		{
 			title: "empty query",
 			src: `root:
@@ -79,3 +210,66 @@ query:
 		})
 	}
 }
+
+func TestConfigNamedQueries(t *testing.T) {
+	config := run.Config{
+		Queries: map[string][]string{
+			"lossless": {"ext=flac"},
+			"fix":      {"@lossless", "@untagged"},
+			"a":        {"@b"},
+			"b":        {"@a"},
+		},
+	}
+
+	for _, tc := range []struct {
+		title   string
+		queries map[string][]string
+		want    map[string][]string
+		err     bool
+	}{
+		{
+			title: "cycle of config",
+			queries: map[string][]string{
+				"untagged": {"artist=^$"},
+			},
+			err: true,
+		},
+		{
+			title: "unknown reference of config",
+			queries: map[string][]string{
+				"b": {"ext=mp3"},
+			},
+			err: true,
+		},
+		{
+			title: "reference each other",
+			queries: map[string][]string{
+				"untagged": {"artist=^$"},
+				"b":        {"@lossless"},
+				"mine":     {"@fix", "@a"},
+			},
+			want: map[string][]string{
+				"lossless": {"ext=flac"},
+				"fix":      {"@lossless", "@untagged"},
+				"a":        {"@b"},
+				"b":        {"@lossless"},
+				"untagged": {"artist=^$"},
+				"mine":     {"@fix", "@a"},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := config.NamedQueries(tc.queries)
+			if tc.err {
+				assert.ErrorIs(t, err, run.ErrConfig)
+				assert.ErrorIs(t, err, run.ErrNamedQuery)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, 4, len(config.Queries), "config is not changed")
+		})
+	}
+}
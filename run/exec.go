@@ -0,0 +1,215 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/query"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ErrExec = errors.New("Exec")
+)
+
+// ExecConfig configures the command executed per selected file or batch of them.
+type ExecConfig struct {
+	// Command is a sh script, {} is replaced with the path or the paths of the batch.
+	Command string
+	// BatchSize is the max number of the files per command, 0 means a command per file.
+	BatchSize int
+	// Jobs is the max number of the commands running concurrently.
+	Jobs int
+	// FailFast stops at the first failure.
+	FailFast bool
+}
+
+// ExecSummary is the summary of the exit statuses.
+type ExecSummary struct {
+	Count   int `json:"count"`
+	Success int `json:"success"`
+	Failed  int `json:"failed"`
+	// ExitStatus is the number of the commands per exit status, -1 means the command did not exit normally.
+	ExitStatus map[int]int `json:"exit_status"`
+}
+
+// Exec runs the command for the selected files like find -exec.
+//
+// The paths are passed as the arguments of the script, not embedded into the script, so that any file names are safe.
+// The metadata are available as environment variables FFLIST_KEY (e.g. FFLIST_ARTIST) for a command per file,
+// and passed to stdin in jsonl format.
+type Exec struct {
+	source   Source
+	selector query.Selector
+	config   ExecConfig
+	script   string
+	stdout   io.Writer
+	stderr   io.Writer
+	verbose  bool
+
+	mux     sync.Mutex
+	summary *ExecSummary
+}
+
+func NewExec(
+	source Source,
+	selector query.Selector,
+	config ExecConfig,
+	stdout, stderr io.Writer,
+	verbose bool,
+) *Exec {
+	return &Exec{
+		source:   source,
+		selector: selector,
+		config:   config,
+		script:   ExecScript(config.Command, config.BatchSize > 0),
+		stdout:   stdout,
+		stderr:   stderr,
+		verbose:  verbose,
+		summary: &ExecSummary{
+			ExitStatus: map[int]int{},
+		},
+	}
+}
+
+// ExecScript replaces {} of the command with the positional parameters.
+// The trailing + of the batch command (e.g. echo {} +) is removed.
+// The parameters are appended if the command does not contain {}.
+func ExecScript(command string, batch bool) string {
+	param := `"$1"`
+	if batch {
+		param = `"$@"`
+		if x := strings.TrimSpace(command); strings.HasSuffix(x, " +") || x == "+" {
+			command = strings.TrimSuffix(x, "+")
+		}
+	}
+	if !strings.Contains(command, "{}") {
+		return strings.TrimSpace(command) + " " + param
+	}
+	return strings.ReplaceAll(command, "{}", param)
+}
+
+func (e *Exec) Run(ctx context.Context) error {
+	startTime := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		eg    errgroup.Group
+		batch []info.Getter
+		flush = func() {
+			if len(batch) == 0 {
+				return
+			}
+			xs := batch
+			batch = nil
+			eg.Go(func() error {
+				if ctx.Err() != nil {
+					// stopped while waiting
+					return nil
+				}
+				if !e.run(ctx, xs) && e.config.FailFast {
+					cancel()
+				}
+				return nil
+			})
+		}
+	)
+	eg.SetLimit(max(e.config.Jobs, 1))
+
+	for data := range Select(ctx, e.selector, e.source) {
+		if ctx.Err() != nil {
+			// drain
+			continue
+		}
+		batch = append(batch, data)
+		if len(batch) >= max(e.config.BatchSize, 1) {
+			flush()
+		}
+	}
+	if ctx.Err() == nil {
+		flush()
+	}
+	_ = eg.Wait()
+
+	writeMetrics(e.verbose, time.Since(startTime))
+	fmt.Fprintf(e.stderr, "%s\n", logx.Jsonify(e.summary))
+	if err := e.source.Err(); err != nil {
+		return err
+	}
+	if e.summary.Failed > 0 {
+		return fmt.Errorf("%w: %d commands failed", ErrExec, e.summary.Failed)
+	}
+	return nil
+}
+
+var envKeyRegexp = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// ExecEnvKey returns the name of the environment variable of the key, e.g. FFLIST_ARTIST.
+func ExecEnvKey(key string) string {
+	return "FFLIST_" + envKeyRegexp.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+func (e *Exec) run(ctx context.Context, batch []info.Getter) bool {
+	var (
+		args  = []string{"-c", e.script, "fflist"}
+		stdin strings.Builder
+		env   = os.Environ()
+	)
+	for _, data := range batch {
+		path, _ := data.Get("path")
+		args = append(args, path)
+		b, err := json.Marshal(data)
+		if err == nil {
+			stdin.Write(b)
+			stdin.WriteByte('\n')
+		}
+	}
+	if e.config.BatchSize == 0 {
+		if m, err := info.ToMap(batch[0]); err == nil {
+			for k, v := range m {
+				env = append(env, ExecEnvKey(k)+"="+v)
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", args...)
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(stdin.String())
+	cmd.Stdout = e.stdout
+	cmd.Stderr = e.stderr
+	err := cmd.Run()
+
+	status := 0
+	if err != nil {
+		status = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = exitErr.ExitCode()
+		}
+		slog.Warn("Exec", slog.Any("args", args[3:]), logx.Err(err))
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.summary.Count++
+	e.summary.ExitStatus[status]++
+	if err == nil {
+		e.summary.Success++
+	} else {
+		e.summary.Failed++
+	}
+	return err == nil
+}
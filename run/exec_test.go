@@ -0,0 +1,178 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecScript(t *testing.T) {
+	for _, tc := range []struct {
+		title   string
+		command string
+		batch   bool
+		want    string
+	}{
+		{
+			title:   "placeholder",
+			command: "echo {}",
+			want:    `echo "$1"`,
+		},
+		{
+			title:   "append",
+			command: "echo",
+			want:    `echo "$1"`,
+		},
+		{
+			title:   "batch",
+			command: "echo {} +",
+			batch:   true,
+			want:    `echo "$@" `,
+		},
+		{
+			title:   "batch append",
+			command: "ls -l",
+			batch:   true,
+			want:    `ls -l "$@"`,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, run.ExecScript(tc.command, tc.batch))
+		})
+	}
+}
+
+func TestExecEnvKey(t *testing.T) {
+	assert.Equal(t, "FFLIST_ARTIST", run.ExecEnvKey("artist"))
+	assert.Equal(t, "FFLIST_ALBUM_ARTIST", run.ExecEnvKey("album-artist"))
+	assert.Equal(t, "FFLIST_STREAMS_0_CODEC_NAME", run.ExecEnvKey("streams.0.codec_name"))
+}
+
+func TestExec(t *testing.T) {
+	const index = `{"path":"a.mp3","artist":"A1"}
+{"path":"b b.mp3","artist":"A2"}
+{"path":"c.mp3","artist":"A1"}`
+
+	for _, tc := range []struct {
+		title   string
+		config  run.ExecConfig
+		query   []string
+		want    []string
+		summary run.ExecSummary
+		err     bool
+	}{
+		{
+			title: "per file",
+			config: run.ExecConfig{
+				Command: `echo "{}:$FFLIST_ARTIST"`,
+			},
+			query: []string{"artist=A1"},
+			want:  []string{"a.mp3:A1", "c.mp3:A1"},
+			summary: run.ExecSummary{
+				Count: 2, Success: 2, ExitStatus: map[int]int{0: 2},
+			},
+		},
+		{
+			title: "batch",
+			config: run.ExecConfig{
+				Command:   `printf "<%s>\n" {} +`,
+				BatchSize: 2,
+			},
+			query: []string{"path=."},
+			want:  []string{"<a.mp3>", "<b b.mp3>", "<c.mp3>"},
+			summary: run.ExecSummary{
+				Count: 2, Success: 2, ExitStatus: map[int]int{0: 2},
+			},
+		},
+		{
+			title: "no env in single file batch",
+			config: run.ExecConfig{
+				Command:   `echo "{}:$FFLIST_ARTIST"`,
+				BatchSize: 2,
+			},
+			query: []string{"path=b"},
+			want:  []string{"b b.mp3:"},
+			summary: run.ExecSummary{
+				Count: 1, Success: 1, ExitStatus: map[int]int{0: 1},
+			},
+		},
+		{
+			title: "stdin",
+			config: run.ExecConfig{
+				Command:   `: {}; wc -l | tr -d " "`,
+				BatchSize: 10,
+			},
+			query: []string{"path=."},
+			want:  []string{"3"},
+			summary: run.ExecSummary{
+				Count: 1, Success: 1, ExitStatus: map[int]int{0: 1},
+			},
+		},
+		{
+			title: "failed",
+			config: run.ExecConfig{
+				Command: `test "{}" = a.mp3 || exit 3`,
+			},
+			query: []string{"path=."},
+			summary: run.ExecSummary{
+				Count: 3, Success: 1, Failed: 2, ExitStatus: map[int]int{0: 1, 3: 2},
+			},
+			err: true,
+		},
+		{
+			title: "fail fast",
+			config: run.ExecConfig{
+				Command:  `exit 3`,
+				FailFast: true,
+			},
+			query: []string{"path=."},
+			summary: run.ExecSummary{
+				Count: 1, Failed: 1, ExitStatus: map[int]int{3: 1},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			selector, err := run.ParseQueryCommandLine(tc.query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer query.Close(selector)
+
+			var stdout, stderr bytes.Buffer
+			err = run.NewExec(
+				run.NewIndexSource(bytes.NewBufferString(index)),
+				selector,
+				tc.config,
+				&stdout,
+				&stderr,
+				false,
+			).Run(context.TODO())
+			if tc.err {
+				assert.True(t, errors.Is(err, run.ErrExec))
+			} else if !assert.Nil(t, err) {
+				return
+			}
+
+			var got []string
+			if x := strings.TrimSpace(stdout.String()); x != "" {
+				got = strings.Split(x, "\n")
+			}
+			assert.Equal(t, tc.want, got)
+
+			lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+			var summary run.ExecSummary
+			if !assert.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &summary)) {
+				return
+			}
+			assert.Equal(t, tc.summary, summary)
+		})
+	}
+}
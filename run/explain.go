@@ -0,0 +1,82 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/query"
+)
+
+// Explain writes the selector tree and the traces of the selector per metadata in jsonl.
+type Explain struct {
+	source   Source
+	selector query.Selector
+	w        io.Writer
+	paths    []string
+}
+
+// NewExplain returns a new Explain.
+// If source is nil, only the selector tree is written.
+// If paths is not empty, only the metadata of paths are explained.
+func NewExplain(
+	source Source,
+	selector query.Selector,
+	w io.Writer,
+	paths []string,
+) *Explain {
+	return &Explain{
+		source:   source,
+		selector: selector,
+		w:        w,
+		paths:    paths,
+	}
+}
+
+// ExplainTree is the first line of the output.
+type ExplainTree struct {
+	Selector *query.Trace `json:"selector"`
+}
+
+// ExplainResult is the trace of the selector for the file.
+type ExplainResult struct {
+	Path   string       `json:"path"`
+	Result bool         `json:"result"`
+	Trace  *query.Trace `json:"trace"`
+}
+
+func (e *Explain) Run(ctx context.Context) error {
+	e.write(ExplainTree{
+		Selector: query.Explain(ctx, e.selector, nil),
+	})
+	if e.source == nil {
+		return nil
+	}
+
+	for data := range e.source.Start(ctx) {
+		path, _ := data.Get("path")
+		if len(e.paths) > 0 && !slices.Contains(e.paths, path) {
+			continue
+		}
+		t := query.Explain(ctx, e.selector, data)
+		e.write(ExplainResult{
+			Path:   path,
+			Result: t.Result != nil && *t.Result,
+			Trace:  t,
+		})
+	}
+	return e.source.Err()
+}
+
+func (e *Explain) write(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to output", logx.Err(err))
+		return
+	}
+	fmt.Fprintf(e.w, "%s\n", b)
+}
@@ -0,0 +1,86 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/fflist/export"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/worker"
+)
+
+// ExportSummary is the summary of the export.
+type ExportSummary struct {
+	Count    int `json:"count"`
+	Exported int `json:"exported"`
+	// Planned is the number of the files to be exported by the dry run.
+	Planned int `json:"planned"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// Export transcodes the selected files and writes the results in jsonl.
+type Export struct {
+	source       Source
+	selector     query.Selector
+	exportWorker *worker.Exporter
+	w            io.Writer
+	summaryW     io.Writer
+	verbose      bool
+}
+
+// NewExport returns a new Export.
+// The results are written to w as they finish, and the summary is written to summaryW.
+func NewExport(
+	source Source,
+	selector query.Selector,
+	exportWorker *worker.Exporter,
+	w io.Writer,
+	summaryW io.Writer,
+	verbose bool,
+) *Export {
+	return &Export{
+		source:       source,
+		selector:     selector,
+		exportWorker: exportWorker,
+		w:            w,
+		summaryW:     summaryW,
+		verbose:      verbose,
+	}
+}
+
+func (e *Export) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	var summary ExportSummary
+	dataC := Select(ctx, e.selector, e.source)
+	for r := range e.exportWorker.Start(ctx, dataC) {
+		summary.Count++
+		switch {
+		case r.Error != "":
+			summary.Failed++
+			slog.Warn("Export", slog.String("path", r.Source), slog.String("err", r.Error))
+		case r.Skip != "":
+			summary.Skipped++
+		case r.DryRun:
+			summary.Planned++
+		default:
+			summary.Exported++
+		}
+		writeJSONLine(e.w, r)
+	}
+
+	writeMetrics(e.verbose, time.Since(startTime))
+	fmt.Fprintf(e.summaryW, "%s\n", logx.Jsonify(summary))
+	if err := e.source.Err(); err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%w: %d files failed", export.ErrExport, summary.Failed)
+	}
+	return ctx.Err()
+}
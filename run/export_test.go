@@ -0,0 +1,66 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/fflist/export"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// noTranscoder fails if it is called.
+type noTranscoder struct{}
+
+func (noTranscoder) Transcode(context.Context, string, string, meta.Preset) error {
+	return errors.New("called")
+}
+
+func TestExportDryRunSummary(t *testing.T) {
+	var (
+		src   = t.TempDir()
+		dest  = t.TempDir()
+		index bytes.Buffer
+	)
+	for _, name := range []string{"a.flac", "b.flac"} {
+		path := filepath.Join(src, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&index, `{"path":%q}`+"\n", path)
+	}
+	exporter, err := export.New(noTranscoder{}, meta.Preset{Ext: ".mp3", Args: []string{"x"}}, dest, nil, []string{src}, true)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var out, summaryOut bytes.Buffer
+	err = run.NewExport(
+		run.NewIndexSource(&index),
+		query.NewTrueSelector(),
+		worker.NewExport(exporter, 2),
+		&out,
+		&summaryOut,
+		false,
+	).Run(context.TODO())
+	if !assert.Nil(t, err) {
+		return
+	}
+	var summary run.ExportSummary
+	if !assert.Nil(t, json.Unmarshal(summaryOut.Bytes(), &summary)) {
+		return
+	}
+	assert.Equal(t, run.ExportSummary{
+		Count:   2,
+		Planned: 2,
+	}, summary)
+}
@@ -0,0 +1,151 @@
+package run
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+)
+
+// KeysMaxDistinct is the max number of distinct values counted per key.
+const KeysMaxDistinct = 10000
+
+// Keys aggregates the keys of the selected metadata and writes them in jsonl.
+type Keys struct {
+	source   Source
+	selector query.Selector
+	w        io.Writer
+	top      int
+	verbose  bool
+}
+
+// NewKeys returns a new Keys.
+// top is the number of the most frequent values written per key, negative is treated as 0.
+func NewKeys(
+	source Source,
+	selector query.Selector,
+	w io.Writer,
+	top int,
+	verbose bool,
+) *Keys {
+	return &Keys{
+		source:   source,
+		selector: selector,
+		w:        w,
+		top:      max(top, 0),
+		verbose:  verbose,
+	}
+}
+
+// KeyStat is the statistics of the key.
+type KeyStat struct {
+	Key string `json:"key"`
+	// Count is the number of the files having the key.
+	Count int `json:"count"`
+	// Type is the inferred type of the values: int, float, duration, date or string.
+	Type string `json:"type"`
+	// Distinct is the number of the distinct values, up to KeysMaxDistinct.
+	Distinct int `json:"distinct"`
+	// Top is the most frequent values.
+	Top []*ValueCount `json:"top"`
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+func (k *Keys) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	stats := map[string]*keyStat{}
+	for data := range Select(ctx, k.selector, k.source) {
+		m, err := info.ToMap(data)
+		if err != nil {
+			slog.Warn("Keys", logx.Err(err))
+			continue
+		}
+		for key, value := range m {
+			s, ok := stats[key]
+			if !ok {
+				s = newKeyStat()
+				stats[key] = s
+			}
+			s.add(value)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(stats)) {
+		b, err := json.Marshal(stats[key].result(key, k.top))
+		if err != nil {
+			slog.Error("Failed to output", slog.String("key", key), logx.Err(err))
+			continue
+		}
+		fmt.Fprintf(k.w, "%s\n", b)
+	}
+
+	writeMetrics(k.verbose, time.Since(startTime))
+	return k.source.Err()
+}
+
+type keyStat struct {
+	count  int
+	typed  bool // true if any non-empty value
+	types  meta.ValueTypes
+	values map[string]int
+}
+
+func newKeyStat() *keyStat {
+	return &keyStat{
+		types:  meta.AllValueTypes,
+		values: map[string]int{},
+	}
+}
+
+func (s *keyStat) add(value string) {
+	s.count++
+	if value != "" {
+		s.typed = true
+		s.types &= meta.TypesOf(value)
+	}
+	if _, ok := s.values[value]; ok || len(s.values) < KeysMaxDistinct {
+		s.values[value]++
+	}
+}
+
+func (s *keyStat) result(key string, top int) *KeyStat {
+	values := make([]*ValueCount, 0, len(s.values))
+	for v, c := range s.values {
+		values = append(values, &ValueCount{
+			Value: v,
+			Count: c,
+		})
+	}
+	slices.SortFunc(values, func(a, b *ValueCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+	typ := meta.StringType
+	if s.typed {
+		typ = s.types.Narrowest()
+	}
+	return &KeyStat{
+		Key:      key,
+		Count:    s.count,
+		Type:     typ.String(),
+		Distinct: len(s.values),
+		Top:      values[:min(max(top, 0), len(values))],
+	}
+}
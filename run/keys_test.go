@@ -0,0 +1,123 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	index := `{"path":"a.mp3","size":"10","duration":"215.5","date":"2024-01-02","length":"00:03:35.50","artist":"A1"}
+{"path":"b.mp3","size":"20","duration":"180","date":"2024","length":"3m35s","artist":"A1"}
+{"path":"c.mp3","size":"30","duration":"60.0","date":"2024-03-04T05:06:07Z","length":"01:02","artist":"A2","title":""}`
+
+	var out bytes.Buffer
+	k := run.NewKeys(
+		run.NewIndexSource(bytes.NewBufferString(index)),
+		query.NewTrueSelector(),
+		&out,
+		1,
+		false,
+	)
+	if !assert.Nil(t, k.Run(context.TODO())) {
+		return
+	}
+
+	got := map[string]*run.KeyStat{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var s run.KeyStat
+		if !assert.Nil(t, json.Unmarshal([]byte(line), &s)) {
+			return
+		}
+		got[s.Key] = &s
+	}
+
+	for key, want := range map[string]*run.KeyStat{
+		"size": {
+			Key: "size", Count: 3, Type: "int", Distinct: 3,
+			Top: []*run.ValueCount{{Value: "10", Count: 1}},
+		},
+		"duration": {
+			Key: "duration", Count: 3, Type: "float", Distinct: 3,
+			Top: []*run.ValueCount{{Value: "180", Count: 1}},
+		},
+		"date": {
+			Key: "date", Count: 3, Type: "date", Distinct: 3,
+			Top: []*run.ValueCount{{Value: "2024", Count: 1}},
+		},
+		"length": {
+			Key: "length", Count: 3, Type: "duration", Distinct: 3,
+			Top: []*run.ValueCount{{Value: "00:03:35.50", Count: 1}},
+		},
+		"artist": {
+			Key: "artist", Count: 3, Type: "string", Distinct: 2,
+			Top: []*run.ValueCount{{Value: "A1", Count: 2}},
+		},
+		"title": {
+			Key: "title", Count: 1, Type: "string", Distinct: 1,
+			Top: []*run.ValueCount{{Value: "", Count: 1}},
+		},
+	} {
+		assert.Equal(t, want, got[key], key)
+	}
+	assert.Equal(t, 7, len(got))
+}
+
+func TestKeysTop(t *testing.T) {
+	index := `{"path":"a.mp3","artist":"A1"}
+{"path":"b.mp3","artist":"A1"}
+{"path":"c.mp3","artist":"A2"}`
+
+	for _, tc := range []struct {
+		title string
+		top   int
+		want  []*run.ValueCount
+	}{
+		{
+			title: "negative",
+			top:   -1,
+			want:  []*run.ValueCount{},
+		},
+		{
+			title: "zero",
+			top:   0,
+			want:  []*run.ValueCount{},
+		},
+		{
+			title: "more than values",
+			top:   5,
+			want:  []*run.ValueCount{{Value: "A1", Count: 2}, {Value: "A2", Count: 1}},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			var out bytes.Buffer
+			k := run.NewKeys(
+				run.NewIndexSource(bytes.NewBufferString(index)),
+				query.NewTrueSelector(),
+				&out,
+				tc.top,
+				false,
+			)
+			if !assert.Nil(t, k.Run(context.TODO())) {
+				return
+			}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				var s run.KeyStat
+				if !assert.Nil(t, json.Unmarshal([]byte(line), &s)) {
+					return
+				}
+				if s.Key == "artist" {
+					assert.Equal(t, tc.want, s.Top)
+					return
+				}
+			}
+			t.Error("no artist")
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/fflist/lint"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/query"
+)
+
+// Lint checks the selected metadata against the rules and writes the violations in jsonl.
+type Lint struct {
+	source   Source
+	selector query.Selector
+	linter   *lint.Linter
+	w        io.Writer
+	verbose  bool
+}
+
+func NewLint(
+	source Source,
+	selector query.Selector,
+	linter *lint.Linter,
+	w io.Writer,
+	verbose bool,
+) *Lint {
+	return &Lint{
+		source:   source,
+		selector: selector,
+		linter:   linter,
+		w:        w,
+		verbose:  verbose,
+	}
+}
+
+func (l *Lint) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	for data := range Select(ctx, l.selector, l.source) {
+		l.write(l.linter.Lint(data))
+	}
+	l.write(l.linter.Flush())
+
+	writeMetrics(l.verbose, time.Since(startTime))
+	return l.source.Err()
+}
+
+func (l *Lint) write(violations []*lint.Violation) {
+	for _, v := range violations {
+		b, err := json.Marshal(v)
+		if err != nil {
+			slog.Error("Failed to output", slog.String("path", v.Path), logx.Err(err))
+			continue
+		}
+		fmt.Fprintf(l.w, "%s\n", b)
+	}
+}
@@ -0,0 +1,123 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/organize"
+	"github.com/berquerant/fflist/query"
+)
+
+var (
+	ErrOrganize = errors.New("Organize")
+)
+
+// Organize places the selected files into the layout and writes the operations in jsonl.
+type Organize struct {
+	source   Source
+	selector query.Selector
+	planner  *organize.Planner
+	w        io.Writer
+	undoLog  io.Writer
+	dryRun   bool
+	verbose  bool
+}
+
+// NewOrganize returns a new Organize.
+// The executed operations are written to undoLog if it is not nil.
+func NewOrganize(
+	source Source,
+	selector query.Selector,
+	planner *organize.Planner,
+	w io.Writer,
+	undoLog io.Writer,
+	dryRun bool,
+	verbose bool,
+) *Organize {
+	return &Organize{
+		source:   source,
+		selector: selector,
+		planner:  planner,
+		w:        w,
+		undoLog:  undoLog,
+		dryRun:   dryRun,
+		verbose:  verbose,
+	}
+}
+
+func (o *Organize) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	var failed int
+	for data := range Select(ctx, o.selector, o.source) {
+		op := o.planner.Plan(data)
+		if !o.dryRun && op.Runnable() {
+			if err := organize.Execute(op); err != nil {
+				op.Error = err.Error()
+			} else if o.undoLog != nil {
+				writeJSONLine(o.undoLog, op)
+			}
+		}
+		if op.Error != "" {
+			failed++
+			slog.Warn("Organize", slog.String("path", op.Source), slog.String("err", op.Error))
+		}
+		writeJSONLine(o.w, op)
+	}
+
+	writeMetrics(o.verbose, time.Since(startTime))
+	if err := o.source.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d files failed", ErrOrganize, failed)
+	}
+	return nil
+}
+
+// UndoOrganize reverts the operations of the undo log in reverse order and writes them in jsonl.
+func UndoOrganize(undoLog io.Reader, w io.Writer) error {
+	var ops []*organize.Op
+	scanner := bufio.NewScanner(undoLog)
+	for scanner.Scan() {
+		var op organize.Op
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return errors.Join(ErrOrganize, err)
+		}
+		ops = append(ops, &op)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Join(ErrOrganize, err)
+	}
+
+	var failed int
+	for _, op := range slices.Backward(ops) {
+		if err := organize.Undo(op); err != nil {
+			failed++
+			op.Error = err.Error()
+			slog.Warn("Undo", slog.String("path", op.Dest), logx.Err(err))
+		}
+		writeJSONLine(w, op)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d files failed to undo", ErrOrganize, failed)
+	}
+	return nil
+}
+
+func writeJSONLine(w io.Writer, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to output", logx.Err(err))
+		return
+	}
+	fmt.Fprintf(w, "%s\n", b)
+}
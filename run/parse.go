@@ -1,21 +1,169 @@
 package run
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/berquerant/fflist/query"
 	"github.com/berquerant/fflist/slicesx"
 )
 
 const (
-	queryShKey = "sh"
+	queryShKey   = "sh"
+	queryShcoKey = "shco"
+	queryExprKey = "expr"
 )
 
-func ParseQuery(args []string) (query.Selector, error) {
+// DefaultCoprocessTimeout is the default timeout of the shco script per record.
+const DefaultCoprocessTimeout = 10 * time.Second
+
+type parseConfig struct {
+	coprocessTimeout time.Duration
+	queries          map[string][]string
+	// resolving is the stack of the named queries being parsed
+	resolving []string
+}
+
+func newParseConfig(opt ...ParseOption) *parseConfig {
+	c := &parseConfig{
+		coprocessTimeout: DefaultCoprocessTimeout,
+		queries:          map[string][]string{},
+	}
+	for _, f := range opt {
+		f(c)
+	}
+	return c
+}
+
+type ParseOption func(*parseConfig)
+
+// WithCoprocessTimeout sets the timeout of the shco script per record.
+// 0 means no timeout.
+func WithCoprocessTimeout(d time.Duration) ParseOption {
+	return func(c *parseConfig) {
+		c.coprocessTimeout = d
+	}
+}
+
+// WithNamedQueries adds the named queries referenced by @name.
+// The later ones win.
+func WithNamedQueries(queries map[string][]string) ParseOption {
+	return func(c *parseConfig) {
+		maps.Copy(c.queries, queries)
+	}
+}
+
+var (
+	ErrNamedQuery = errors.New("NamedQuery")
+)
+
+const namedQueryPrefix = "@"
+
+// NamedQueryName returns the name of the named query reference, e.g. lossless of @lossless.
+func NamedQueryName(arg string) (string, bool) {
+	if !strings.HasPrefix(arg, namedQueryPrefix) || strings.Contains(arg, "=") {
+		return "", false
+	}
+	return strings.TrimPrefix(arg, namedQueryPrefix), true
+}
+
+// CheckNamedQueries returns an error if the named queries have invalid names, unknown references or cycles.
+func CheckNamedQueries(queries map[string][]string) error {
+	var (
+		done  = map[string]bool{}
+		visit func(name string, stack []string) error
+	)
+	visit = func(name string, stack []string) error {
+		if slices.Contains(stack, name) {
+			return fmt.Errorf("%w: cycle %s", ErrNamedQuery, formatNamedQueryPath(append(stack, name)))
+		}
+		if done[name] {
+			return nil
+		}
+		args, ok := queries[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown %s%s in %s", ErrNamedQuery, namedQueryPrefix, name, formatNamedQueryPath(stack))
+		}
+		for _, a := range args {
+			if x, ok := NamedQueryName(a); ok {
+				if err := visit(x, append(stack, name)); err != nil {
+					return err
+				}
+			}
+		}
+		done[name] = true
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(queries)) {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("%w: invalid name %q", ErrNamedQuery, name)
+		}
+		if len(queries[name]) == 0 {
+			return fmt.Errorf("%w: empty %s%s", ErrNamedQuery, namedQueryPrefix, name)
+		}
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatNamedQueryPath(names []string) string {
+	r := make([]string, len(names))
+	for i, x := range names {
+		r[i] = namedQueryPrefix + x
+	}
+	return strings.Join(r, " -> ")
+}
+
+func ParseQuery(args []string, opt ...ParseOption) (query.Selector, error) {
+	return newParseConfig(opt...).parseQuery(args)
+}
+
+// parseNamedQuery parses the named query as a command line.
+func (c *parseConfig) parseNamedQuery(name string) (query.Selector, error) {
+	stack := append(c.resolving, name)
+	if slices.Contains(c.resolving, name) {
+		return nil, fmt.Errorf("%w: cycle %s", ErrNamedQuery, formatNamedQueryPath(stack))
+	}
+	args, ok := c.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown %s%s", ErrNamedQuery, namedQueryPrefix, name)
+	}
+
+	c.resolving = stack
+	defer func() {
+		c.resolving = c.resolving[:len(c.resolving)-1]
+	}()
+	s, err := c.parseCommandLine(args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: in %s%s", err, namedQueryPrefix, name)
+	}
+	return s, nil
+}
+
+func (c *parseConfig) parseQuery(args []string) (query.Selector, error) {
 	r := make([]query.Selector, len(args))
 	for i, a := range args {
-		a = os.ExpandEnv(a)
+		if name, ok := NamedQueryName(a); ok {
+			s, err := c.parseNamedQuery(name)
+			if err != nil {
+				return nil, fmt.Errorf("%w: index %d", err, i)
+			}
+			r[i] = s
+			continue
+		}
+
+		// expr has its own $env
+		if !strings.HasPrefix(a, queryExprKey+"=") {
+			a = os.ExpandEnv(a)
+		}
 		x, err := query.Parse(a)
 		if err != nil {
 			return nil, fmt.Errorf("%w: index %d", err, i)
@@ -25,6 +173,13 @@ func ParseQuery(args []string) (query.Selector, error) {
 		switch x.Key() {
 		case queryShKey:
 			s = query.NewScriptSelector(x)
+		case queryShcoKey:
+			s = query.NewCoprocessSelector(x, c.coprocessTimeout)
+		case queryExprKey:
+			s, err = query.NewExprSelector(x)
+			if err != nil {
+				return nil, fmt.Errorf("%w: index %d", err, i)
+			}
 		default:
 			s, err = query.NewRegexpSelector(x)
 			if err != nil {
@@ -37,11 +192,15 @@ func ParseQuery(args []string) (query.Selector, error) {
 	return query.NewAndSelector(r...), nil
 }
 
-func ParseQueryCommandLine(args []string) (query.Selector, error) {
+func ParseQueryCommandLine(args []string, opt ...ParseOption) (query.Selector, error) {
+	return newParseConfig(opt...).parseCommandLine(args)
+}
+
+func (c *parseConfig) parseCommandLine(args []string) (query.Selector, error) {
 	xs := slicesx.Chunk(args, "or", "OR")
 	r := make([]query.Selector, len(xs))
 	for i, x := range xs {
-		s, err := ParseQuery(x)
+		s, err := c.parseQuery(x)
 		if err != nil {
 			return nil, err
 		}
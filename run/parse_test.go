@@ -0,0 +1,156 @@
+package run_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNamedQueries(t *testing.T) {
+	for _, tc := range []struct {
+		title   string
+		queries map[string][]string
+		err     bool
+	}{
+		{
+			title: "empty",
+		},
+		{
+			title: "reference",
+			queries: map[string][]string{
+				"a": {"@b", "or", "@c"},
+				"b": {"@c", "artist=B"},
+				"c": {"ext=mp3"},
+			},
+		},
+		{
+			title: "unknown",
+			queries: map[string][]string{
+				"a": {"@b"},
+			},
+			err: true,
+		},
+		{
+			title: "self",
+			queries: map[string][]string{
+				"a": {"@a"},
+			},
+			err: true,
+		},
+		{
+			title: "cycle",
+			queries: map[string][]string{
+				"a": {"@b"},
+				"b": {"ext=mp3", "or", "@c"},
+				"c": {"@a"},
+			},
+			err: true,
+		},
+		{
+			title: "empty query",
+			queries: map[string][]string{
+				"a": {},
+			},
+			err: true,
+		},
+		{
+			title: "invalid name",
+			queries: map[string][]string{
+				"a b": {"ext=mp3"},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			err := run.CheckNamedQueries(tc.queries)
+			if tc.err {
+				assert.ErrorIs(t, err, run.ErrNamedQuery)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestParseNamedQuery(t *testing.T) {
+	queries := map[string][]string{
+		"lossless": {`ext=\.(flac|wav)$`},
+		"untagged": {"artist=^$", "or", "title=^$"},
+		"fix":      {"@lossless", "@untagged"},
+		"loop":     {"@loop"},
+	}
+	data := func(kv ...string) info.Getter {
+		m := map[string]string{}
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return info.New(meta.NewData(m))
+	}
+
+	for _, tc := range []struct {
+		title string
+		args  []string
+		data  info.Getter
+		want  bool
+		err   error
+	}{
+		{
+			title: "reference",
+			args:  []string{"@lossless"},
+			data:  data("ext", ".flac"),
+			want:  true,
+		},
+		{
+			title: "reference and query",
+			args:  []string{"@lossless", "artist=A"},
+			data:  data("ext", ".flac", "artist", "B"),
+			want:  false,
+		},
+		{
+			title: "or in reference",
+			args:  []string{"@untagged"},
+			data:  data("artist", "A", "title", ""),
+			want:  true,
+		},
+		{
+			title: "nested",
+			args:  []string{"@fix"},
+			data:  data("ext", ".wav", "artist", "", "title", "T"),
+			want:  true,
+		},
+		{
+			title: "nested not matched",
+			args:  []string{"@fix"},
+			data:  data("ext", ".mp3", "artist", "", "title", "T"),
+			want:  false,
+		},
+		{
+			title: "unknown",
+			args:  []string{"@unknown"},
+			err:   run.ErrNamedQuery,
+		},
+		{
+			title: "cycle",
+			args:  []string{"@loop"},
+			err:   run.ErrNamedQuery,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s, err := run.ParseQueryCommandLine(tc.args, run.WithNamedQueries(queries))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer query.Close(s)
+			assert.Equal(t, tc.want, s.Select(context.TODO(), tc.data))
+		})
+	}
+}
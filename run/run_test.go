@@ -0,0 +1,139 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	fsys := fstest.MapFS{
+		"music/a.mp3":     &fstest.MapFile{Data: []byte("a")},
+		"music/b.flac":    &fstest.MapFile{Data: []byte("bb")},
+		"music/c.flac":    &fstest.MapFile{Data: []byte("ccc")},
+		"video/d.mp4":     &fstest.MapFile{Data: []byte("dddd")},
+		"music/e.jpg":     &fstest.MapFile{},
+		"music/sub/f.m4a": &fstest.MapFile{},
+	}
+	prober, err := meta.ReadFixture(bytes.NewBufferString(`{"path":"music/a.mp3","artist":"A1"}
+{"path":"music/b.flac","artist":"A2","size":"100"}
+{"path":"music/c.flac","artist":"A1"}
+{"path":"video/d.mp4","artist":"A1"}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, tc := range []struct {
+		title string
+		root  []string
+		query []string
+		want  []string
+	}{
+		{
+			title: "artist",
+			root:  []string{"music", "video"},
+			query: []string{"artist=A1"},
+			want:  []string{"music/a.mp3", "music/c.flac", "video/d.mp4"},
+		},
+		{
+			title: "or",
+			root:  []string{"music"},
+			query: []string{"artist=A2", "or", "ext=m4a"},
+			want:  []string{"music/b.flac", "music/sub/f.m4a"},
+		},
+		{
+			title: "and",
+			root:  []string{"."},
+			query: []string{"artist=A1", "size=^[34]$"},
+			want:  []string{"music/c.flac", "video/d.mp4"},
+		},
+		{
+			title: "stat wins over fixture",
+			root:  []string{"music"},
+			query: []string{"size=^2$"},
+			want:  []string{"music/b.flac"},
+		},
+		{
+			title: "expr",
+			root:  []string{"."},
+			query: []string{`expr=$env["artist"] == "A1" && int(size) >= 3`},
+			want:  []string{"music/c.flac", "video/d.mp4"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			selector, err := run.ParseQueryCommandLine(tc.query)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			var (
+				out         bytes.Buffer
+				walkWorker  = worker.NewWalker(func() walk.Walker { return walk.NewFS(fsys) })
+				probeWorker = worker.NewProbe(prober, 2)
+				writer      = run.NewWriter(&out, selector, false)
+			)
+			q := run.NewQuery(run.NewWalkSource(tc.root, walkWorker, probeWorker), writer)
+			if !assert.Nil(t, q.Run(context.TODO())) {
+				return
+			}
+
+			got := strings.Fields(out.String())
+			slices.Sort(got)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestQueryChapterRecords(t *testing.T) {
+	fsys := fstest.MapFS{
+		"book.m4b": &fstest.MapFile{},
+		"song.mp3": &fstest.MapFile{},
+	}
+	prober := meta.NewFixtureProber(map[string]map[string]string{
+		"book.m4b": {
+			"chapter_count":         "2",
+			"chapters.0.start_time": "0.000000",
+			"chapters.0.end_time":   "60.000000",
+			"chapters.0.tags.title": "Prologue",
+			"chapters.1.start_time": "60.000000",
+			"chapters.1.end_time":   "120.000000",
+			"chapters.1.tags.title": "Epilogue",
+		},
+		"song.mp3": {},
+	})
+	selector, err := run.ParseQueryCommandLine([]string{"chapter.title=Epi"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var (
+		out         bytes.Buffer
+		walkWorker  = worker.NewWalker(func() walk.Walker { return walk.NewFS(fsys) })
+		probeWorker = worker.NewProbe(prober, 1, worker.WithExpanders(info.NewChapterExpander()))
+		writer      = run.NewWriter(&out, selector, true)
+	)
+	if !assert.Nil(t, run.NewQuery(run.NewWalkSource([]string{"."}, walkWorker, probeWorker), writer).Run(context.TODO())) {
+		return
+	}
+
+	var got map[string]string
+	if !assert.Nil(t, json.Unmarshal(out.Bytes(), &got)) {
+		return
+	}
+	assert.Equal(t, "book.m4b", got["path"])
+	assert.Equal(t, "1", got["chapter.index"])
+	assert.Equal(t, "Epilogue", got["chapter.title"])
+	assert.Equal(t, "60.000000", got["chapter.start"])
+	assert.Equal(t, "120.000000", got["chapter.end"])
+}
@@ -0,0 +1,214 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/berquerant/fflist/group"
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/metric"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/worker"
+)
+
+// Source produces metadata.
+type Source interface {
+	Start(ctx context.Context) <-chan info.Getter
+	Err() error
+}
+
+var (
+	_ Source = &WalkSource{}
+	_ Source = &IndexSource{}
+	_ Source = &GroupSource{}
+)
+
+// WalkSource walks the roots and probes the files.
+type WalkSource struct {
+	root        []string
+	walkWorker  *worker.Walker
+	probeWorker *worker.Prober
+}
+
+func NewWalkSource(root []string, walkWorker *worker.Walker, probeWorker *worker.Prober) *WalkSource {
+	return &WalkSource{
+		root:        ExpandEnvAll(root...),
+		walkWorker:  walkWorker,
+		probeWorker: probeWorker,
+	}
+}
+
+func (s *WalkSource) Start(ctx context.Context) <-chan info.Getter {
+	entryC := s.walkWorker.Start(ctx, s.root...)
+	return s.probeWorker.Start(ctx, entryC)
+}
+
+func (s WalkSource) Err() error { return s.walkWorker.Err() }
+
+func (s WalkSource) WorkerNum() int { return s.probeWorker.WorkerNum() }
+
+func (s WalkSource) ProbeFailedCount() uint64 { return s.probeWorker.FailedCount() }
+
+const (
+	indexSourceBufferSize = 100
+	indexMaxLineSize      = 16 * 1024 * 1024
+)
+
+// IndexSource reads metadata from the index.
+type IndexSource struct {
+	r   io.Reader
+	err error
+}
+
+func NewIndexSource(r io.Reader) *IndexSource {
+	return &IndexSource{
+		r: r,
+	}
+}
+
+func (s *IndexSource) Start(ctx context.Context) <-chan info.Getter {
+	s.err = nil
+	resultC := make(chan info.Getter, indexSourceBufferSize)
+
+	go func() {
+		defer close(resultC)
+
+		scanner := bufio.NewScanner(s.r)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), indexMaxLineSize)
+		for scanner.Scan() {
+			d := map[string]string{}
+			if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+				slog.Warn("IndexSource", logx.Err(err))
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case resultC <- info.New(meta.NewData(d)):
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			s.err = err
+		}
+	}()
+
+	return resultC
+}
+
+func (s IndexSource) Err() error { return s.err }
+
+// GroupSource assembles the metadata from the source into groups, e.g. albums,
+// and produces them with the aggregate keys of the groups after reading all of them.
+type GroupSource struct {
+	source  Source
+	by      group.By
+	records bool
+}
+
+// NewGroupSource returns a new GroupSource.
+// If records is true, produces a record per group instead of the files.
+func NewGroupSource(source Source, by group.By, records bool) *GroupSource {
+	return &GroupSource{
+		source:  source,
+		by:      by,
+		records: records,
+	}
+}
+
+func (s *GroupSource) Start(ctx context.Context) <-chan info.Getter {
+	resultC := make(chan info.Getter, indexSourceBufferSize)
+
+	go func() {
+		defer close(resultC)
+
+		g := group.New(s.by)
+		for data := range s.source.Start(ctx) {
+			g.Add(data)
+		}
+
+		var xs []info.Getter
+		if s.records {
+			xs = g.Records()
+		} else {
+			xs = g.Files()
+		}
+		for _, x := range xs {
+			select {
+			case <-ctx.Done():
+				return
+			case resultC <- x:
+			}
+		}
+	}()
+
+	return resultC
+}
+
+func (s GroupSource) Err() error { return s.source.Err() }
+
+func (s GroupSource) WorkerNum() int { return workerNumOf(s.source) }
+
+func (s GroupSource) ProbeFailedCount() uint64 { return probeFailedCountOf(s.source) }
+
+// Select starts the source and passes only the metadata selected by the selector.
+// The selector runs in parallel by the workers of the source, e.g. the probe workers,
+// because the selector may run scripts.
+func Select(ctx context.Context, selector query.Selector, source Source) <-chan info.Getter {
+	var (
+		wg      sync.WaitGroup
+		dataC   = source.Start(ctx)
+		resultC = make(chan info.Getter, indexSourceBufferSize)
+	)
+
+	for range workerNumOf(source) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for data := range dataC {
+				if !selector.Select(ctx, data) {
+					continue
+				}
+				metric.IncrAcceptCount()
+				resultC <- data
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultC)
+	}()
+
+	return resultC
+}
+
+// workerSource is a Source producing the metadata by the workers.
+type workerSource interface {
+	WorkerNum() int
+}
+
+func workerNumOf(source Source) int {
+	if s, ok := source.(workerSource); ok && s.WorkerNum() > 1 {
+		return s.WorkerNum()
+	}
+	return 1
+}
+
+// probeSource is a Source probing the files.
+type probeSource interface {
+	ProbeFailedCount() uint64
+}
+
+// probeFailedCountOf returns the number of the files failed to probe by the source.
+func probeFailedCountOf(source Source) uint64 {
+	if s, ok := source.(probeSource); ok {
+		return s.ProbeFailedCount()
+	}
+	return 0
+}
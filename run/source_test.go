@@ -0,0 +1,143 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestIndexSource(t *testing.T) {
+	errRead := errors.New("read")
+	// longer than the default limit of bufio.Scanner
+	long := strings.Repeat("x", 100*1024)
+
+	for _, tc := range []struct {
+		title string
+		r     io.Reader
+		want  []string
+		err   error
+	}{
+		{
+			title: "index",
+			r: strings.NewReader(`{"path":"a.mp3"}
+invalid
+{"path":"b.mp3"}
+`),
+			want: []string{"a.mp3", "b.mp3"},
+		},
+		{
+			title: "long line",
+			r:     strings.NewReader(`{"path":"a.mp3","lyrics":"` + long + `"}`),
+			want:  []string{"a.mp3"},
+		},
+		{
+			title: "read error",
+			r: &errReader{
+				r:   strings.NewReader(`{"path":"a.mp3"}` + "\n"),
+				err: errRead,
+			},
+			want: []string{"a.mp3"},
+			err:  errRead,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := run.NewIndexSource(tc.r)
+			got := []string{}
+			for x := range s.Start(context.TODO()) {
+				path, _ := x.Get("path")
+				got = append(got, path)
+			}
+			assert.Equal(t, tc.want, got)
+			assert.ErrorIs(t, s.Err(), tc.err)
+		})
+	}
+}
+
+// concurrencySelector records the max number of the concurrent Select.
+type concurrencySelector struct {
+	mux      sync.Mutex
+	current  int
+	maxCount int
+}
+
+func (s *concurrencySelector) Select(_ context.Context, _ info.Getter) bool {
+	s.mux.Lock()
+	s.current++
+	s.maxCount = max(s.maxCount, s.current)
+	s.mux.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	s.mux.Lock()
+	s.current--
+	s.mux.Unlock()
+	return true
+}
+
+func TestSelect(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := range 8 {
+		fsys[fmt.Sprintf("%d.mp3", i)] = &fstest.MapFile{}
+	}
+	prober := meta.NewFixtureProber(map[string]map[string]string{})
+
+	for _, tc := range []struct {
+		title     string
+		workerNum int
+		want      int
+	}{
+		{
+			title:     "serial",
+			workerNum: 1,
+			want:      1,
+		},
+		{
+			title:     "parallel",
+			workerNum: 4,
+			want:      4,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			var (
+				selector = &concurrencySelector{}
+				source   = run.NewWalkSource(
+					[]string{"."},
+					worker.NewWalker(func() walk.Walker { return walk.NewFS(fsys) }),
+					worker.NewProbe(prober, tc.workerNum),
+				)
+				got int
+			)
+			for range run.Select(context.TODO(), selector, source) {
+				got++
+			}
+			assert.Equal(t, 8, got)
+			assert.Equal(t, tc.want, selector.maxCount)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/metric"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/syncdir"
+)
+
+// SyncSummary is the summary of the sync.
+type SyncSummary struct {
+	Count   int `json:"count"`
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+}
+
+// Sync copies the selected files into the destination and writes the actions in jsonl.
+type Sync struct {
+	source      Source
+	selector    query.Selector
+	syncer      *syncdir.Syncer
+	w           io.Writer
+	summaryW    io.Writer
+	deleteExtra bool
+	untracked   bool
+	verbose     bool
+}
+
+// NewSync returns a new Sync.
+// If deleteExtra is true, the files in the destination that are not selected are removed.
+// The files not recorded in the manifest are removed too if untracked is true.
+// The files are not removed if the source fails, any file fails to be probed or the selector fails,
+// because the selection may be partial.
+// The summary is written to summaryW.
+func NewSync(
+	source Source,
+	selector query.Selector,
+	syncer *syncdir.Syncer,
+	w io.Writer,
+	summaryW io.Writer,
+	deleteExtra bool,
+	untracked bool,
+	verbose bool,
+) *Sync {
+	return &Sync{
+		source:      source,
+		selector:    selector,
+		syncer:      syncer,
+		w:           w,
+		summaryW:    summaryW,
+		deleteExtra: deleteExtra,
+		untracked:   untracked,
+		verbose:     verbose,
+	}
+}
+
+func (s *Sync) Run(ctx context.Context) error {
+	startTime := time.Now()
+	selectErrorCount := metric.Get().SelectErrorCount
+
+	var summary SyncSummary
+	write := func(a *syncdir.Action) {
+		switch {
+		case a.Error != "":
+			summary.Failed++
+			slog.Warn("Sync", slog.String("path", a.Dest), slog.String("err", a.Error))
+		case a.Kind == syncdir.Add:
+			summary.Added++
+		case a.Kind == syncdir.Update:
+			summary.Updated++
+		case a.Kind == syncdir.Skip:
+			summary.Skipped++
+		case a.Kind == syncdir.Delete:
+			summary.Deleted++
+		}
+		writeJSONLine(s.w, a)
+	}
+
+	for data := range Select(ctx, s.selector, s.source) {
+		if ctx.Err() != nil {
+			// drain
+			continue
+		}
+		summary.Count++
+		write(s.syncer.Sync(data))
+	}
+
+	sourceErr := s.source.Err()
+	// do not delete the files by the partial selection
+	if s.deleteExtra && sourceErr == nil && ctx.Err() == nil {
+		if n := probeFailedCountOf(s.source); n > 0 {
+			slog.Warn("Skip deletion because files failed to be probed", slog.Uint64("count", n))
+		} else if n := metric.Get().SelectErrorCount - selectErrorCount; n > 0 {
+			slog.Warn("Skip deletion because the query failed", slog.Uint64("count", n))
+		} else {
+			for _, a := range s.syncer.Delete(s.untracked) {
+				write(a)
+			}
+		}
+	}
+	closeErr := s.syncer.Close()
+
+	writeMetrics(s.verbose, time.Since(startTime))
+	fmt.Fprintf(s.summaryW, "%s\n", logx.Jsonify(summary))
+	switch {
+	case sourceErr != nil:
+		return sourceErr
+	case closeErr != nil:
+		return closeErr
+	case summary.Failed > 0:
+		return fmt.Errorf("%w: %d files failed", syncdir.ErrSync, summary.Failed)
+	default:
+		return ctx.Err()
+	}
+}
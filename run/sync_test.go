@@ -0,0 +1,101 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+	"github.com/berquerant/fflist/run"
+	"github.com/berquerant/fflist/syncdir"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncDeletePartialSelection(t *testing.T) {
+	var (
+		src  = t.TempDir()
+		dest = t.TempDir()
+		a    = filepath.Join(src, "a.mp3")
+		b    = filepath.Join(src, "b.mp3")
+	)
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	regexpSelector, err := query.NewRegexpSelector(query.NewQuery("comment", "fav"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	exprSelector, err := run.ParseQueryCommandLine([]string{"expr=int(comment) > 0"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	sync := func(t *testing.T, selector query.Selector, fixture map[string]map[string]string) error {
+		t.Helper()
+		syncer, err := syncdir.New(dest, []string{src}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out, summary bytes.Buffer
+		return run.NewSync(
+			run.NewWalkSource(
+				[]string{src},
+				worker.NewWalker(func() walk.Walker { return walk.NewFile() }),
+				worker.NewProbe(meta.NewFixtureProber(fixture), 2),
+			),
+			selector,
+			syncer,
+			&out,
+			&summary,
+			true,
+			false,
+			false,
+		).Run(context.TODO())
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dest, name))
+		return err == nil
+	}
+
+	assert.Nil(t, sync(t, regexpSelector, map[string]map[string]string{
+		a: {"comment": "fav"},
+		b: {"comment": "fav"},
+	}))
+	assert.True(t, exists("a.mp3"))
+	assert.True(t, exists("b.mp3"))
+
+	// b fails to be probed
+	assert.Nil(t, sync(t, regexpSelector, map[string]map[string]string{
+		a: {"comment": "fav"},
+	}))
+	assert.True(t, exists("b.mp3"), "not deleted because of the probe failure")
+
+	assert.Nil(t, sync(t, regexpSelector, map[string]map[string]string{
+		a: {"comment": "fav"},
+		b: {"comment": "other"},
+	}))
+	assert.False(t, exists("b.mp3"))
+
+	// b fails to be selected
+	assert.Nil(t, sync(t, exprSelector, map[string]map[string]string{
+		a: {"comment": "1"},
+		b: {"comment": "1"},
+	}))
+	assert.True(t, exists("b.mp3"))
+	assert.Nil(t, sync(t, exprSelector, map[string]map[string]string{
+		a: {"comment": "1"},
+		b: {"comment": "x"},
+	}))
+	assert.True(t, exists("b.mp3"), "not deleted because of the selector failure")
+	assert.Nil(t, sync(t, exprSelector, map[string]map[string]string{
+		a: {"comment": "1"},
+		b: {"comment": "0"},
+	}))
+	assert.False(t, exists("b.mp3"))
+}
@@ -0,0 +1,315 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/iox"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/query"
+)
+
+var (
+	ErrTag = errors.New("Tag")
+)
+
+// TagChange is a change of a tag, nil means the tag does not exist.
+type TagChange struct {
+	Key    string  `json:"key"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+// TagResult is the changes of the tags of the file.
+type TagResult struct {
+	Path    string       `json:"path"`
+	Changes []*TagChange `json:"changes"`
+	DryRun  bool         `json:"dry_run,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// TagOperation returns the changes of the tags of the file.
+// data has only the tags, not the other metadata, e.g. name and duration.
+type TagOperation func(data info.Getter) []*TagChange
+
+// lookupTag finds the tag ignoring case, e.g. ALBUM of flac.
+func lookupTag(data info.Getter, key string) (string, *string) {
+	if v, ok := data.Get(key); ok {
+		return key, &v
+	}
+	m, err := info.ToMap(data)
+	if err != nil {
+		return key, nil
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return k, &v
+		}
+	}
+	return key, nil
+}
+
+// SetTags sets the values of the tags.
+func SetTags(tags map[string]string) TagOperation {
+	return func(data info.Getter) []*TagChange {
+		var r []*TagChange
+		for _, k := range slices.Sorted(maps.Keys(tags)) {
+			v := tags[k]
+			key, before := lookupTag(data, k)
+			if before != nil && *before == v {
+				continue
+			}
+			r = append(r, &TagChange{
+				Key:    key,
+				Before: before,
+				After:  &v,
+			})
+		}
+		return r
+	}
+}
+
+// UnsetTags removes the tags.
+func UnsetTags(keys []string) TagOperation {
+	return func(data info.Getter) []*TagChange {
+		var r []*TagChange
+		for _, k := range keys {
+			key, before := lookupTag(data, k)
+			if before == nil {
+				continue
+			}
+			r = append(r, &TagChange{
+				Key:    key,
+				Before: before,
+			})
+		}
+		return r
+	}
+}
+
+// RenameTagKey moves the value of the tag from oldKey to newKey.
+func RenameTagKey(oldKey, newKey string) TagOperation {
+	return func(data info.Getter) []*TagChange {
+		key, value := lookupTag(data, oldKey)
+		if value == nil {
+			return nil
+		}
+		_, before := lookupTag(data, newKey)
+		return []*TagChange{
+			{
+				Key:    key,
+				Before: value,
+			},
+			{
+				Key:    newKey,
+				Before: before,
+				After:  value,
+			},
+		}
+	}
+}
+
+// Tag rewrites the tags of the selected files and writes the changes in jsonl.
+//
+// The tags are read from the selected files, not from the metadata of the selection.
+// The tags are written into a temporary file in the same directory,
+// and the temporary file replaces the original file after the tags of it are verified.
+// The symbolic links are resolved, and the targets are rewritten.
+// The files having several hard links are not rewritten unless force is true,
+// because the replacement breaks the links.
+// The members of archives are not rewritten.
+type Tag struct {
+	source    Source
+	selector  query.Selector
+	operation TagOperation
+	tagWriter meta.TagWriter
+	tagReader meta.TagReader
+	w         io.Writer
+	force     bool
+	dryRun    bool
+	verbose   bool
+}
+
+// NewTag returns a new Tag.
+// tagReader reads the tags of the files to be rewritten and verifies the rewritten files.
+func NewTag(
+	source Source,
+	selector query.Selector,
+	operation TagOperation,
+	tagWriter meta.TagWriter,
+	tagReader meta.TagReader,
+	w io.Writer,
+	force bool,
+	dryRun bool,
+	verbose bool,
+) *Tag {
+	return &Tag{
+		source:    source,
+		selector:  selector,
+		operation: operation,
+		tagWriter: tagWriter,
+		tagReader: tagReader,
+		w:         w,
+		force:     force,
+		dryRun:    dryRun,
+		verbose:   verbose,
+	}
+}
+
+func (t *Tag) Run(ctx context.Context) error {
+	startTime := time.Now()
+
+	var failed int
+	for data := range Select(ctx, t.selector, t.source) {
+		path, _ := data.Get("path")
+		changes, err := t.changes(ctx, data)
+		if err == nil && len(changes) == 0 {
+			continue
+		}
+		r := &TagResult{
+			Path:    path,
+			Changes: changes,
+			DryRun:  t.dryRun,
+		}
+		if err == nil {
+			err = t.tag(ctx, path, changes)
+		}
+		if err != nil {
+			failed++
+			r.Error = err.Error()
+			slog.Warn("Tag", slog.String("path", path), logx.Err(err))
+		}
+
+		b, err := json.Marshal(r)
+		if err != nil {
+			slog.Error("Failed to output", slog.String("path", path), logx.Err(err))
+			continue
+		}
+		fmt.Fprintf(t.w, "%s\n", b)
+	}
+
+	writeMetrics(t.verbose, time.Since(startTime))
+	if err := t.source.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d files failed", ErrTag, failed)
+	}
+	return nil
+}
+
+// changes reads the tags of the file and returns the changes of them.
+func (t *Tag) changes(ctx context.Context, data info.Getter) ([]*TagChange, error) {
+	if _, ok := data.Get("archive"); ok {
+		return nil, fmt.Errorf("%w: cannot rewrite the member of the archive", ErrTag)
+	}
+	path, _ := data.Get("path")
+	tags, err := t.tagReader.ReadTags(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read: %w", ErrTag, err)
+	}
+	return t.operation(info.New(meta.NewData(tags))), nil
+}
+
+func (t *Tag) tag(ctx context.Context, path string, changes []*TagChange) error {
+	path, stat, err := t.target(path)
+	if err != nil {
+		return err
+	}
+	if t.dryRun {
+		return nil
+	}
+	return t.apply(ctx, path, stat, changes)
+}
+
+// target returns the file to be rewritten, the target of the symbolic link.
+func (t *Tag) target(path string) (string, fs.FileInfo, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, err
+	}
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return "", nil, fmt.Errorf("%w: not a regular file", ErrTag)
+	}
+	if n, ok := iox.Nlink(stat); ok && n > 1 && !t.force {
+		return "", nil, fmt.Errorf("%w: %d hard links, the other links would keep the original", ErrTag, n)
+	}
+	return path, stat, nil
+}
+
+func (t *Tag) apply(ctx context.Context, path string, stat fs.FileInfo, changes []*TagChange) error {
+	// keep the extension for the muxer
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fflist-tag-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	removeTmp := true
+	defer func() {
+		if removeTmp {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	tags := make(map[string]*string, len(changes))
+	for _, c := range changes {
+		tags[c.Key] = c.After
+	}
+	if err := t.tagWriter.WriteTags(ctx, path, tmpPath, tags); err != nil {
+		if stderr := meta.Stderr(err); stderr != "" {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+		}
+		return err
+	}
+	if err := t.verify(ctx, tmpPath, changes); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, stat.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := iox.CopyOwner(tmpPath, stat); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	removeTmp = false
+	return nil
+}
+
+// verify reads the tags of the written file and compares them.
+func (t *Tag) verify(ctx context.Context, path string, changes []*TagChange) error {
+	tags, err := t.tagReader.ReadTags(ctx, path)
+	if err != nil {
+		return fmt.Errorf("%w: verify: %w", ErrTag, err)
+	}
+	data := info.New(meta.NewData(tags))
+	for _, c := range changes {
+		_, got := lookupTag(data, c.Key)
+		switch {
+		case c.After == nil && got != nil && *got != "":
+			return fmt.Errorf("%w: verify: %s is not removed", ErrTag, c.Key)
+		case c.After != nil && *c.After != "" && (got == nil || *got != *c.After):
+			return fmt.Errorf("%w: verify: %s is not written", ErrTag, c.Key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/fflist/run"
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonTagFile is a media file of json object of tags.
+type jsonTagFile struct {
+	// broken makes the written file lose the tags.
+	broken bool
+}
+
+func (jsonTagFile) read(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var d map[string]string
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (f jsonTagFile) WriteTags(_ context.Context, input, output string, tags map[string]*string) error {
+	d, err := f.read(input)
+	if err != nil {
+		return err
+	}
+	if !f.broken {
+		for k, v := range tags {
+			if v == nil {
+				delete(d, k)
+				continue
+			}
+			d[k] = *v
+		}
+	}
+	b, _ := json.Marshal(d)
+	return os.WriteFile(output, b, 0600)
+}
+
+func (f jsonTagFile) ReadTags(_ context.Context, path string) (map[string]string, error) {
+	return f.read(path)
+}
+
+func TestTag(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		dir := t.TempDir()
+		var index bytes.Buffer
+		for name, tags := range map[string]string{
+			"a.flac": `{"ALBUM":"A","artist":"X","comment":"c"}`,
+			"b.flac": `{"album":"B","artist":"Y"}`,
+		} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(tags), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// the metadata other than the tags
+			fmt.Fprintf(&index, `{"path":%q,"name":%q,"size":"1",%s`+"\n", path, name, strings.TrimPrefix(tags, "{"))
+		}
+		return dir, index.String()
+	}
+	ptr := func(s string) *string { return &s }
+
+	for _, tc := range []struct {
+		title     string
+		query     []string
+		operation run.TagOperation
+		dryRun    bool
+		broken    bool
+		want      map[string]map[string]string
+		changes   map[string][]*run.TagChange
+		err       bool
+	}{
+		{
+			title:     "set",
+			query:     []string{"artist=X"},
+			operation: run.SetTags(map[string]string{"album": "A2", "genre": "G"}),
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A2", "artist": "X", "comment": "c", "genre": "G"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "ALBUM", Before: ptr("A"), After: ptr("A2")},
+					{Key: "genre", After: ptr("G")},
+				},
+			},
+		},
+		{
+			title:     "dry run",
+			query:     []string{"artist=X"},
+			operation: run.SetTags(map[string]string{"album": "A2"}),
+			dryRun:    true,
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "artist": "X", "comment": "c"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "ALBUM", Before: ptr("A"), After: ptr("A2")},
+				},
+			},
+		},
+		{
+			title:     "not a tag",
+			query:     []string{"artist=X"},
+			operation: run.SetTags(map[string]string{"name": "N"}),
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "artist": "X", "comment": "c", "name": "N"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "name", After: ptr("N")},
+				},
+			},
+		},
+		{
+			title:     "rename not a tag",
+			query:     []string{"path=."},
+			operation: run.RenameTagKey("size", "bytes"),
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "artist": "X", "comment": "c"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{},
+		},
+		{
+			title:     "unset",
+			query:     []string{"path=."},
+			operation: run.UnsetTags([]string{"comment"}),
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "artist": "X"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "comment", Before: ptr("c")},
+				},
+			},
+		},
+		{
+			title:     "rename key",
+			query:     []string{"path=."},
+			operation: run.RenameTagKey("artist", "album_artist"),
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "album_artist": "X", "comment": "c"},
+				"b.flac": {"album": "B", "album_artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "artist", Before: ptr("X")},
+					{Key: "album_artist", After: ptr("X")},
+				},
+				"b.flac": {
+					{Key: "artist", Before: ptr("Y")},
+					{Key: "album_artist", After: ptr("Y")},
+				},
+			},
+		},
+		{
+			title:     "verification failure keeps the original",
+			query:     []string{"artist=X"},
+			operation: run.SetTags(map[string]string{"album": "A2"}),
+			broken:    true,
+			want: map[string]map[string]string{
+				"a.flac": {"ALBUM": "A", "artist": "X", "comment": "c"},
+				"b.flac": {"album": "B", "artist": "Y"},
+			},
+			changes: map[string][]*run.TagChange{
+				"a.flac": {
+					{Key: "ALBUM", Before: ptr("A"), After: ptr("A2")},
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			dir, index := setup(t)
+			selector, err := run.ParseQueryCommandLine(tc.query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			f := jsonTagFile{broken: tc.broken}
+
+			var out bytes.Buffer
+			err = run.NewTag(
+				run.NewIndexSource(bytes.NewBufferString(index)),
+				selector,
+				tc.operation,
+				f,
+				f,
+				&out,
+				false,
+				tc.dryRun,
+				false,
+			).Run(context.TODO())
+			if tc.err {
+				assert.ErrorIs(t, err, run.ErrTag)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			gotChanges := map[string][]*run.TagChange{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				var r run.TagResult
+				if !assert.Nil(t, json.Unmarshal([]byte(line), &r)) {
+					return
+				}
+				assert.Equal(t, tc.dryRun, r.DryRun)
+				assert.Equal(t, tc.err, r.Error != "")
+				gotChanges[filepath.Base(r.Path)] = r.Changes
+			}
+			assert.Equal(t, tc.changes, gotChanges)
+
+			for name, want := range tc.want {
+				got, err := f.read(filepath.Join(dir, name))
+				if !assert.Nil(t, err) {
+					return
+				}
+				assert.Equal(t, want, got, name)
+			}
+			entries, err := os.ReadDir(dir)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, 2, len(entries), "no temporary files")
+		})
+	}
+}
+
+func TestTagLinks(t *testing.T) {
+	const content = `{"album":"A"}`
+	set := run.SetTags(map[string]string{"album": "A2"})
+	newTag := func(t *testing.T, index string, force bool) (*bytes.Buffer, error) {
+		t.Helper()
+		selector, err := run.ParseQueryCommandLine([]string{"path=."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var (
+			f   jsonTagFile
+			out bytes.Buffer
+		)
+		err = run.NewTag(
+			run.NewIndexSource(bytes.NewBufferString(index)),
+			selector,
+			set,
+			f,
+			f,
+			&out,
+			force,
+			false,
+			false,
+		).Run(context.TODO())
+		return &out, err
+	}
+	read := func(t *testing.T, path string) string {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	t.Run("symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "a.flac")
+		link := filepath.Join(dir, "link.flac")
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+		_, err := newTag(t, fmt.Sprintf(`{"path":%q,"album":"A"}`+"\n", link), false)
+		assert.Nil(t, err)
+		stat, err := os.Lstat(link)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, os.ModeSymlink, stat.Mode().Type(), "the link is kept")
+		assert.Equal(t, `{"album":"A2"}`, read(t, target))
+	})
+
+	t.Run("hardlink", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.flac")
+		other := filepath.Join(dir, "b.flac")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Link(path, other); err != nil {
+			t.Skip(err)
+		}
+		index := fmt.Sprintf(`{"path":%q,"album":"A"}`+"\n", path)
+
+		out, err := newTag(t, index, false)
+		assert.ErrorIs(t, err, run.ErrTag)
+		assert.Contains(t, out.String(), "hard links")
+		assert.Equal(t, content, read(t, path))
+
+		_, err = newTag(t, index, true)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"album":"A2"}`, read(t, path))
+		assert.Equal(t, content, read(t, other))
+	})
+
+	t.Run("archive member", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.zip")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		member := path + "!/a.flac"
+		out, err := newTag(t, fmt.Sprintf(`{"path":%q,"archive":%q,"album":"A"}`+"\n", member, path), false)
+		assert.ErrorIs(t, err, run.ErrTag)
+		assert.Contains(t, out.String(), "archive")
+		assert.Equal(t, content, read(t, path))
+	})
+}
@@ -32,12 +32,12 @@ func (w *Writer) Write(ctx context.Context, data info.Getter) error {
 	if !w.selector.Select(ctx, data) {
 		return nil
 	}
+	metric.IncrAcceptCount()
 	return w.write(data)
 }
 
+// write writes the selected data.
 func (w *Writer) write(data info.Getter) error {
-	metric.IncrAcceptCount()
-
 	if w.verbose {
 		b, err := json.Marshal(data)
 		if err != nil {
@@ -53,7 +53,11 @@ func (w *Writer) write(data info.Getter) error {
 }
 
 func (w *Writer) WriteMetrics(duration time.Duration) {
-	if !w.verbose {
+	writeMetrics(w.verbose, duration)
+}
+
+func writeMetrics(verbose bool, duration time.Duration) {
+	if !verbose {
 		return
 	}
 
@@ -0,0 +1,381 @@
+// Package syncdir makes a directory contain the copies of the selected files.
+package syncdir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/iox"
+	"github.com/berquerant/fflist/layout"
+)
+
+var (
+	ErrSync = errors.New("Sync")
+)
+
+// ManifestName is the name of the manifest file in the destination.
+const ManifestName = ".fflist-sync.json"
+
+// Entry is a synced file of the manifest.
+type Entry struct {
+	Source  string    `json:"source"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Hash is sha256 of the content.
+	Hash string `json:"hash"`
+}
+
+// Manifest records the synced files by the path relative to the destination.
+type Manifest struct {
+	Files map[string]*Entry `json:"files"`
+}
+
+// ReadManifest reads the manifest in the directory.
+// Returns an empty manifest if it does not exist.
+func ReadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{
+		Files: map[string]*Entry{},
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errors.Join(ErrSync, err)
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%w: manifest: %w", ErrSync, err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]*Entry{}
+	}
+	return m, nil
+}
+
+// Write writes the manifest into the directory through a temporary file.
+func (m *Manifest) Write(dir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Join(ErrSync, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".fflist-sync-*")
+	if err != nil {
+		return errors.Join(ErrSync, err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return errors.Join(ErrSync, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Join(ErrSync, err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, ManifestName)); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Join(ErrSync, err)
+	}
+	return nil
+}
+
+// Kind is the kind of the action.
+type Kind string
+
+const (
+	Add    Kind = "add"
+	Update Kind = "update"
+	Skip   Kind = "skip"
+	Delete Kind = "delete"
+)
+
+// Action is an action of the sync.
+type Action struct {
+	Kind   Kind   `json:"action"`
+	Source string `json:"source,omitempty"`
+	Dest   string `json:"dest"`
+	// Reason is why the file is skipped.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Syncer copies the files into the destination mirroring the paths relative to the base directories.
+//
+// The files are compared with the manifest by size and modification time,
+// and by hash if they differ, so that re-runs copy only the changed files.
+type Syncer struct {
+	dest     string
+	bases    []string
+	dryRun   bool
+	manifest *Manifest
+	synced   map[string]bool
+}
+
+// New returns a new Syncer reading the manifest of the destination.
+func New(dest string, bases []string, dryRun bool) (*Syncer, error) {
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%w: no base", ErrSync)
+	}
+	dest = filepath.Clean(dest)
+	if err := CheckDest(dest, bases); err != nil {
+		return nil, err
+	}
+	m, err := ReadManifest(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{
+		dest:     dest,
+		bases:    bases,
+		dryRun:   dryRun,
+		manifest: m,
+		synced:   map[string]bool{},
+	}, nil
+}
+
+// Sync copies the file if the destination is missing or changed.
+func (s *Syncer) Sync(data info.Getter) *Action {
+	path, _ := data.Get("path")
+	a := &Action{
+		Source: path,
+	}
+	rel, err := layout.Rel(s.bases, path)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if rel == ManifestName {
+		a.Error = fmt.Sprintf("%s: conflicts with the manifest", path)
+		return a
+	}
+	a.Dest = filepath.Join(s.dest, rel)
+	if s.synced[rel] {
+		a.Kind = Skip
+		a.Reason = "duplicated"
+		return a
+	}
+	s.synced[rel] = true
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	var (
+		entry          = s.manifest.Files[rel]
+		destStat, dErr = os.Stat(a.Dest)
+		destExists     = dErr == nil
+	)
+	if destExists && os.SameFile(stat, destStat) {
+		a.Error = fmt.Sprintf("%s: same path", path)
+		return a
+	}
+	if destExists && entry != nil && destStat.Size() == entry.Size &&
+		entry.Source == path && entry.Size == stat.Size() && entry.ModTime.Equal(stat.ModTime()) {
+		a.Kind = Skip
+		a.Reason = "up to date"
+		return a
+	}
+
+	hash, err := fileHash(path)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	newEntry := &Entry{
+		Source:  path,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+		Hash:    hash,
+	}
+	if destExists && destStat.Size() == stat.Size() && sameContent(entry, a.Dest, destStat.Size(), hash) {
+		// e.g. touched, or copied before the manifest
+		a.Kind = Skip
+		a.Reason = "same content"
+		if !s.dryRun {
+			s.manifest.Files[rel] = newEntry
+		}
+		return a
+	}
+
+	a.Kind = Add
+	if destExists {
+		a.Kind = Update
+	}
+	if s.dryRun {
+		return a
+	}
+	if err := os.MkdirAll(filepath.Dir(a.Dest), 0755); err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if err := iox.CopyFile(path, a.Dest); err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	s.manifest.Files[rel] = newEntry
+	return a
+}
+
+// Delete removes the files in the destination that are not synced by this run.
+// Only the files recorded in the manifest are removed unless untracked is true,
+// so that the files not copied by the sync are kept.
+func (s *Syncer) Delete(untracked bool) []*Action {
+	if _, err := os.Stat(s.dest); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	var (
+		r     []*Action
+		paths []string
+		found = map[string]bool{}
+	)
+	err := filepath.WalkDir(s.dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dest, path)
+		if err != nil {
+			return err
+		}
+		found[rel] = true
+		if rel == ManifestName || s.synced[rel] {
+			return nil
+		}
+		if _, ok := s.manifest.Files[rel]; !ok && !untracked {
+			return nil
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return []*Action{{
+			Kind:  Delete,
+			Dest:  s.dest,
+			Error: err.Error(),
+		}}
+	}
+
+	for _, rel := range paths {
+		a := &Action{
+			Kind: Delete,
+			Dest: filepath.Join(s.dest, rel),
+		}
+		if e, ok := s.manifest.Files[rel]; ok {
+			a.Source = e.Source
+		}
+		r = append(r, a)
+		if s.dryRun {
+			continue
+		}
+		if err := os.Remove(a.Dest); err != nil {
+			a.Error = err.Error()
+			continue
+		}
+		delete(s.manifest.Files, rel)
+		removeEmptyDirs(s.dest, filepath.Dir(a.Dest))
+	}
+	if !s.dryRun {
+		// the files removed by hand
+		for rel := range s.manifest.Files {
+			if !found[rel] {
+				delete(s.manifest.Files, rel)
+			}
+		}
+	}
+	return r
+}
+
+// Close writes the manifest.
+func (s *Syncer) Close() error {
+	if s.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(s.dest, 0755); err != nil {
+		return errors.Join(ErrSync, err)
+	}
+	return s.manifest.Write(s.dest)
+}
+
+// CheckDest returns an error if the destination and the directories overlap,
+// because the walks of the directories would find the copies,
+// and the deletion in the destination would remove the sources.
+func CheckDest(dest string, dirs []string) error {
+	d, err := resolvePath(dest)
+	if err != nil {
+		return errors.Join(ErrSync, err)
+	}
+	for _, dir := range dirs {
+		x, err := resolvePath(dir)
+		if err != nil {
+			return errors.Join(ErrSync, err)
+		}
+		if isUnder(d, x) || isUnder(x, d) {
+			return fmt.Errorf("%w: destination %s overlaps %s", ErrSync, dest, dir)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path, resolving the symbolic links if the path exists.
+func resolvePath(path string) (string, error) {
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if x, err := filepath.EvalSymlinks(p); err == nil {
+		return x, nil
+	}
+	return p, nil
+}
+
+// isUnder returns true if path is dir or under dir.
+func isUnder(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// removeEmptyDirs removes the empty directories from dir up to root, exclusive.
+func removeEmptyDirs(root, dir string) {
+	for dir != root && len(dir) > len(root) {
+		if err := os.Remove(dir); err != nil {
+			// not empty
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func sameContent(entry *Entry, dest string, destSize int64, hash string) bool {
+	if entry != nil && entry.Size == destSize {
+		return entry.Hash == hash
+	}
+	x, err := fileHash(dest)
+	return err == nil && x == hash
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,192 @@
+package syncdir_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/syncdir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncer(t *testing.T) {
+	var (
+		src  = t.TempDir()
+		dest = t.TempDir()
+	)
+	write := func(t *testing.T, path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(t *testing.T, path string) string {
+		b, err := os.ReadFile(path)
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		return string(b)
+	}
+	data := func(name string) info.Getter {
+		return info.New(meta.NewData(map[string]string{
+			"path": filepath.Join(src, name),
+		}))
+	}
+	sync := func(t *testing.T, deleteExtra, untracked bool, names ...string) map[string]*syncdir.Action {
+		s, err := syncdir.New(dest, []string{src}, false)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		r := map[string]*syncdir.Action{}
+		for _, name := range names {
+			a := s.Sync(data(name))
+			assert.Equal(t, "", a.Error)
+			r[name] = a
+		}
+		if deleteExtra {
+			for _, a := range s.Delete(untracked) {
+				assert.Equal(t, "", a.Error)
+				rel, _ := filepath.Rel(dest, a.Dest)
+				r[rel] = a
+			}
+		}
+		assert.Nil(t, s.Close())
+		return r
+	}
+
+	write(t, filepath.Join(src, "A/a.mp3"), "a")
+	write(t, filepath.Join(src, "A/b.mp3"), "b")
+	write(t, filepath.Join(src, "B/c.mp3"), "c")
+	write(t, filepath.Join(dest, "A/a.mp3"), "a")
+	write(t, filepath.Join(dest, "X/extra.mp3"), "extra")
+
+	t.Run("first", func(t *testing.T) {
+		got := sync(t, false, false, "A/a.mp3", "A/b.mp3")
+		assert.Equal(t, syncdir.Skip, got["A/a.mp3"].Kind)
+		assert.Equal(t, "same content", got["A/a.mp3"].Reason)
+		assert.Equal(t, syncdir.Add, got["A/b.mp3"].Kind)
+		assert.Equal(t, "b", read(t, filepath.Join(dest, "A/b.mp3")))
+
+		m, err := syncdir.ReadManifest(dest)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 2, len(m.Files))
+		assert.Equal(t, filepath.Join(src, "A/b.mp3"), m.Files["A/b.mp3"].Source)
+		assert.Equal(t, int64(1), m.Files["A/b.mp3"].Size)
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		write(t, filepath.Join(src, "A/b.mp3"), "bb")
+		if err := os.Chtimes(filepath.Join(src, "A/b.mp3"), future, future); err != nil {
+			t.Fatal(err)
+		}
+		got := sync(t, false, false, "A/a.mp3", "A/b.mp3", "B/c.mp3")
+		assert.Equal(t, syncdir.Skip, got["A/a.mp3"].Kind)
+		assert.Equal(t, "up to date", got["A/a.mp3"].Reason)
+		assert.Equal(t, syncdir.Update, got["A/b.mp3"].Kind)
+		assert.Equal(t, syncdir.Add, got["B/c.mp3"].Kind)
+		assert.Equal(t, "bb", read(t, filepath.Join(dest, "A/b.mp3")))
+		stat, err := os.Stat(filepath.Join(dest, "A/b.mp3"))
+		if assert.Nil(t, err) {
+			assert.True(t, stat.ModTime().Equal(future))
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		got := sync(t, true, false, "A/a.mp3", "B/c.mp3")
+		assert.Equal(t, syncdir.Delete, got["A/b.mp3"].Kind)
+		assert.Equal(t, filepath.Join(src, "A/b.mp3"), got["A/b.mp3"].Source)
+		_, ok := got["X/extra.mp3"]
+		assert.False(t, ok, "untracked file is kept")
+		assert.Equal(t, "extra", read(t, filepath.Join(dest, "X/extra.mp3")))
+	})
+
+	t.Run("delete untracked", func(t *testing.T) {
+		got := sync(t, true, true, "A/a.mp3", "B/c.mp3")
+		assert.Equal(t, syncdir.Delete, got["X/extra.mp3"].Kind)
+		_, err := os.Stat(filepath.Join(dest, "X"))
+		assert.True(t, os.IsNotExist(err), "empty directory is removed")
+
+		var files []string
+		_ = filepath.WalkDir(dest, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(dest, path)
+				files = append(files, rel)
+			}
+			return err
+		})
+		assert.Equal(t, []string{syncdir.ManifestName, "A/a.mp3", "B/c.mp3"}, files)
+
+		m, err := syncdir.ReadManifest(dest)
+		if assert.Nil(t, err) {
+			assert.Equal(t, 2, len(m.Files))
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		write(t, filepath.Join(src, "C/d.mp3"), "d")
+		s, err := syncdir.New(dest, []string{src}, true)
+		if !assert.Nil(t, err) {
+			return
+		}
+		a := s.Sync(data("C/d.mp3"))
+		assert.Equal(t, syncdir.Add, a.Kind)
+		assert.Equal(t, 2, len(s.Delete(false)))
+		assert.Nil(t, s.Close())
+
+		_, err = os.Stat(filepath.Join(dest, "C/d.mp3"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dest, "A/a.mp3"))
+		assert.Nil(t, err)
+	})
+}
+
+func TestCheckDest(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		title string
+		dest  string
+		dirs  []string
+		err   bool
+	}{
+		{
+			title: "separated",
+			dest:  filepath.Join(dir, "dest"),
+			dirs:  []string{filepath.Join(dir, "src"), filepath.Join(dir, "destination")},
+		},
+		{
+			title: "same",
+			dest:  filepath.Join(dir, "src"),
+			dirs:  []string{filepath.Join(dir, "src")},
+			err:   true,
+		},
+		{
+			title: "dest under dir",
+			dest:  filepath.Join(dir, "src", "dest"),
+			dirs:  []string{filepath.Join(dir, "other"), filepath.Join(dir, "src")},
+			err:   true,
+		},
+		{
+			title: "dir under dest",
+			dest:  dir,
+			dirs:  []string{filepath.Join(dir, "src")},
+			err:   true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			err := syncdir.CheckDest(tc.dest, tc.dirs)
+			if tc.err {
+				assert.ErrorIs(t, err, syncdir.ErrSync)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
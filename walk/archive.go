@@ -0,0 +1,355 @@
+package walk
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/metric"
+)
+
+// Opener is an Entry that cannot be read from the path, e.g. a member of an archive.
+type Opener interface {
+	Entry
+	Open() (io.ReadCloser, error)
+}
+
+const (
+	// ArchiveSeparator separates the path of the archive and the name of the member.
+	ArchiveSeparator = "!/"
+)
+
+var (
+	_ Walker = &ArchiveWalker{}
+)
+
+// ArchiveWalker yields the members of zip and tar archives found by the walker
+// instead of the archives themselves, e.g. bundle.zip!/dir/track.wav.
+// Other files are yielded as is.
+type ArchiveWalker struct {
+	walker Walker
+}
+
+func NewArchive(walker Walker) *ArchiveWalker {
+	return &ArchiveWalker{
+		walker: walker,
+	}
+}
+
+func (w ArchiveWalker) Err() error { return w.walker.Err() }
+
+func (w *ArchiveWalker) Walk(root string) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		for entry := range w.walker.Walk(root) {
+			list := listArchive(entry.Path())
+			if list == nil {
+				if !yield(entry) {
+					return
+				}
+				continue
+			}
+
+			var (
+				members []Entry
+				err     error
+			)
+			for m, mErr := range list {
+				if mErr != nil {
+					err = mErr
+					break
+				}
+				members = append(members, m)
+			}
+			if err != nil {
+				slog.Warn("ArchiveWalker", slog.String("path", entry.Path()), logx.Err(err))
+				// fallback to the archive itself
+				if !yield(entry) {
+					return
+				}
+				continue
+			}
+
+			for _, m := range members {
+				slog.Debug("ArchiveWalker", slog.String("path", m.Path()))
+				metric.IncrEntryCount()
+				if !yield(m) {
+					return
+				}
+			}
+		}
+	}
+}
+
+var (
+	ErrArchive = errors.New("Archive")
+)
+
+type archiveType int
+
+const (
+	unknownArchive archiveType = iota
+	zipArchive
+	tarArchive
+	tarGzipArchive
+)
+
+func archiveTypeOf(path string) archiveType {
+	p := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(p, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(p, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return tarGzipArchive
+	default:
+		return unknownArchive
+	}
+}
+
+// listArchive returns nil if path is not an archive.
+func listArchive(path string) iter.Seq2[Entry, error] {
+	switch t := archiveTypeOf(path); t {
+	case zipArchive:
+		return listZip(path)
+	case tarArchive, tarGzipArchive:
+		return listTar(path, t)
+	default:
+		return nil
+	}
+}
+
+func listZip(path string) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			yield(nil, errors.Join(ErrArchive, err))
+			return
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			e := newArchiveEntry(path, f.Name, f.FileInfo(), map[string]string{
+				"archive_compressed_size": fmt.Sprint(f.CompressedSize64),
+				"archive_method":          fmt.Sprint(f.Method),
+				"crc32":                   fmt.Sprintf("%08x", f.CRC32),
+			})
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func listTar(path string, t archiveType) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		r, err := openTar(path, t)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer r.Close()
+
+		for {
+			h, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, errors.Join(ErrArchive, err))
+				return
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			e := newArchiveEntry(path, h.Name, h.FileInfo(), map[string]string{
+				"uid":   fmt.Sprint(h.Uid),
+				"gid":   fmt.Sprint(h.Gid),
+				"owner": h.Uname,
+				"group": h.Gname,
+			})
+			e.offset = r.dataOffset(h)
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// tarReader is a tar.Reader with the underlying file.
+type tarReader struct {
+	*tar.Reader
+	// file is the archive if it is not compressed.
+	file    *os.File
+	closers []io.Closer
+}
+
+// dataOffset returns the offset of the content of the current member in the archive,
+// or -1 if the archive is compressed or the member is sparse.
+func (r *tarReader) dataOffset(h *tar.Header) int64 {
+	if r.file == nil {
+		return -1
+	}
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return -1
+		}
+	}
+	// tar.Reader does not read ahead, so the file is at the beginning of the content after Next()
+	offset, err := r.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	return offset
+}
+
+func (r *tarReader) Close() error {
+	var errs []error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		errs = append(errs, r.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+func openTar(path string, t archiveType) (*tarReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Join(ErrArchive, err)
+	}
+	if t != tarGzipArchive {
+		return &tarReader{
+			Reader:  tar.NewReader(f),
+			file:    f,
+			closers: []io.Closer{f},
+		}, nil
+	}
+
+	g, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Join(ErrArchive, err)
+	}
+	return &tarReader{
+		Reader:  tar.NewReader(g),
+		closers: []io.Closer{f, g},
+	}, nil
+}
+
+var (
+	_ Opener    = &archiveEntry{}
+	_ MetaEntry = &archiveEntry{}
+)
+
+type archiveEntry struct {
+	archive string
+	name    string
+	info    fs.FileInfo
+	meta    map[string]string
+	// offset is the offset of the content in the uncompressed tar, -1 if unknown.
+	offset int64
+}
+
+func newArchiveEntry(archive, name string, info fs.FileInfo, meta map[string]string) *archiveEntry {
+	meta["archive"] = archive
+	meta["archive_member"] = name
+	return &archiveEntry{
+		archive: archive,
+		name:    name,
+		info:    info,
+		meta:    meta,
+		offset:  -1,
+	}
+}
+
+func (e archiveEntry) Path() string            { return e.archive + ArchiveSeparator + e.name }
+func (e archiveEntry) Info() fs.FileInfo       { return e.info }
+func (e archiveEntry) Meta() map[string]string { return e.meta }
+func (e archiveEntry) Open() (io.ReadCloser, error) {
+	switch t := archiveTypeOf(e.archive); t {
+	case zipArchive:
+		return e.openZip()
+	case tarArchive, tarGzipArchive:
+		return e.openTar(t)
+	default:
+		return nil, fmt.Errorf("%w: unknown archive %s", ErrArchive, e.archive)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error { return r.close() }
+
+func (e archiveEntry) openZip() (io.ReadCloser, error) {
+	r, err := zip.OpenReader(e.archive)
+	if err != nil {
+		return nil, errors.Join(ErrArchive, err)
+	}
+	for _, f := range r.File {
+		if f.Name != e.name {
+			continue
+		}
+		x, err := f.Open()
+		if err != nil {
+			r.Close()
+			return nil, errors.Join(ErrArchive, err)
+		}
+		return &readCloser{
+			Reader: x,
+			close: func() error {
+				return errors.Join(x.Close(), r.Close())
+			},
+		}, nil
+	}
+	r.Close()
+	return nil, fmt.Errorf("%w: %s not found", ErrArchive, e.Path())
+}
+
+func (e archiveEntry) openTar(t archiveType) (io.ReadCloser, error) {
+	if e.offset >= 0 {
+		// seek to the content instead of scanning the members
+		f, err := os.Open(e.archive)
+		if err != nil {
+			return nil, errors.Join(ErrArchive, err)
+		}
+		return &readCloser{
+			Reader: io.NewSectionReader(f, e.offset, e.info.Size()),
+			close:  f.Close,
+		}, nil
+	}
+
+	r, err := openTar(e.archive, t)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := r.Next()
+		if err != nil {
+			r.Close()
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: %s not found", ErrArchive, e.Path())
+			}
+			return nil, errors.Join(ErrArchive, err)
+		}
+		if h.Name == e.name && h.Typeflag == tar.TypeReg {
+			return &readCloser{
+				Reader: r,
+				close:  r.Close,
+			}, nil
+		}
+	}
+}
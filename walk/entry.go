@@ -0,0 +1,54 @@
+package walk
+
+import "io/fs"
+
+// MetaEntry is an Entry with pre-known metadata.
+type MetaEntry interface {
+	Entry
+	Meta() map[string]string
+}
+
+type metaEntry struct {
+	Entry
+	meta map[string]string
+}
+
+func (e *metaEntry) Meta() map[string]string { return e.meta }
+
+func NewMetaEntry(entry Entry, meta map[string]string) MetaEntry {
+	return &metaEntry{
+		Entry: entry,
+		meta:  meta,
+	}
+}
+
+// FSEntry is an Entry in fs.FS, the path is relative to the root of the fs.FS.
+type FSEntry interface {
+	Entry
+	FS() fs.FS
+}
+
+type fsEntry struct {
+	Entry
+	fsys fs.FS
+}
+
+func (e *fsEntry) FS() fs.FS { return e.fsys }
+
+func NewFSEntry(entry Entry, fsys fs.FS) FSEntry {
+	return &fsEntry{
+		Entry: entry,
+		fsys:  fsys,
+	}
+}
+
+// IsVirtual returns true if the path of the entry is not a path of the OS,
+// e.g. a member of an archive, an entry in fs.FS.
+func IsVirtual(entry Entry) bool {
+	switch entry.(type) {
+	case FSEntry, Opener:
+		return true
+	default:
+		return false
+	}
+}
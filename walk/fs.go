@@ -0,0 +1,121 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"strings"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/metric"
+)
+
+var (
+	_ Walker = &FSWalker{}
+)
+
+// NewFS returns a new FSWalker.
+// WithFollowSymlinks, WithOneFileSystem and WithOrdered are ignored.
+func NewFS(fsys fs.FS, opt ...Option) *FSWalker {
+	return &FSWalker{
+		fsys:   fsys,
+		config: newConfig(opt...),
+	}
+}
+
+// FSWalker walks only files under the root in fs.FS, e.g. fstest.MapFS, embed.FS.
+// The root and the paths of entries are slash-separated paths in fs.FS.
+type FSWalker struct {
+	fsys   fs.FS
+	config *config
+	err    error
+}
+
+func (w FSWalker) Err() error { return w.err }
+
+func (w *FSWalker) Walk(root string) iter.Seq[Entry] {
+	w.err = nil
+
+	return func(yield func(Entry) bool) {
+		resultC := make(chan Entry, walkerBufferSize)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			defer close(resultC)
+
+			err := fs.WalkDir(w.fsys, root, func(path string, d fs.DirEntry, err error) error {
+				slog.Debug("FSWalker", slog.String("path", path), logx.Err(err))
+				metric.IncrEntryCount()
+
+				select {
+				case <-ctx.Done():
+					return fs.SkipAll
+				default:
+				}
+				if err != nil {
+					return err
+				}
+
+				depth := fsDepth(root, path)
+				if d.IsDir() {
+					return w.enterDir(path, depth)
+				}
+				if depth < w.config.minDepth {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return fs.SkipAll
+				case resultC <- NewFSEntry(NewEntry(path, info), w.fsys):
+					return nil
+				}
+			})
+			if err != nil {
+				w.err = err
+			}
+		}()
+
+		for x := range resultC {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+func (w *FSWalker) enterDir(path string, depth int) error {
+	if w.config.maxDepth >= 0 && depth >= w.config.maxDepth {
+		return fs.SkipDir
+	}
+	if depth == 0 || len(w.config.skipDirs) == 0 {
+		return nil
+	}
+	metric.IncrDirCount()
+	entries, err := fs.ReadDir(w.fsys, path)
+	if err != nil {
+		return err
+	}
+	if w.config.skipDir(path, entries) {
+		slog.Debug("FSWalker skip dir", slog.String("path", path))
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// fsDepth returns the number of levels of path below the root.
+func fsDepth(root, path string) int {
+	if path == root {
+		return 0
+	}
+	rel := path
+	if root != "." {
+		rel = strings.TrimPrefix(path, root+"/")
+	}
+	return strings.Count(rel, "/") + 1
+}
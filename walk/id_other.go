@@ -0,0 +1,9 @@
+//go:build !unix
+
+package walk
+
+import "io/fs"
+
+func fileIDOf(_ fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
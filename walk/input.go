@@ -0,0 +1,118 @@
+package walk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// InputFormat is the format of the input of ReaderWalker.
+type InputFormat string
+
+const (
+	// LineInput is newline-separated paths.
+	LineInput InputFormat = "line"
+	// NullInput is NUL-separated paths, e.g. find -print0.
+	NullInput InputFormat = "null"
+	// JSONLInput is JSON objects per line, with path and optional pre-known metadata.
+	JSONLInput InputFormat = "jsonl"
+)
+
+var (
+	ErrInput = errors.New("Input")
+)
+
+func ParseInputFormat(s string) (InputFormat, error) {
+	switch f := InputFormat(s); f {
+	case LineInput, NullInput, JSONLInput:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: unknown format %s", ErrInput, s)
+	}
+}
+
+const (
+	// DefaultInputBufferSize is the default max size of a line of the input.
+	DefaultInputBufferSize = 1024 * 1024
+)
+
+// WithInputFormat sets the format of the input of ReaderWalker.
+func WithInputFormat(f InputFormat) Option {
+	return func(c *config) {
+		c.inputFormat = f
+	}
+}
+
+// WithInputBufferSize sets the max size of a line of the input of ReaderWalker.
+func WithInputBufferSize(n int) Option {
+	return func(c *config) {
+		c.inputBufferSize = n
+	}
+}
+
+func (c config) newScanner(r io.Reader) *bufio.Scanner {
+	size := c.inputBufferSize
+	if size < 1 {
+		size = DefaultInputBufferSize
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(size, bufio.MaxScanTokenSize)), size)
+	if c.inputFormat == NullInput {
+		scanner.Split(scanNull)
+	}
+	return scanner
+}
+
+// scanNull is a split function for NUL-separated input.
+func scanNull(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parseInput returns the path and the pre-known metadata.
+func (c config) parseInput(b []byte) (string, map[string]string, error) {
+	if c.inputFormat != JSONLInput {
+		return string(b), nil, nil
+	}
+
+	var (
+		d   = map[string]any{}
+		dec = json.NewDecoder(bytes.NewReader(b))
+	)
+	dec.UseNumber()
+	if err := dec.Decode(&d); err != nil {
+		return "", nil, errors.Join(ErrInput, err)
+	}
+
+	r := make(map[string]string, len(d))
+	for k, v := range d {
+		switch v := v.(type) {
+		case string:
+			r[k] = v
+		default:
+			x, err := json.Marshal(v)
+			if err != nil {
+				return "", nil, errors.Join(ErrInput, err)
+			}
+			r[k] = string(x)
+		}
+	}
+
+	path, ok := r["path"]
+	if !ok || path == "" {
+		return "", nil, fmt.Errorf("%w: no path: %s", ErrInput, b)
+	}
+	delete(r, "path")
+	return path, r, nil
+}
@@ -0,0 +1,120 @@
+package walk
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+type config struct {
+	followSymlinks bool
+	oneFileSystem  bool
+	visited        *Visited
+	maxDepth       int
+	minDepth       int
+	skipDirs       []SkipDirFunc
+	ordered        bool
+	// ReaderWalker
+	inputFormat     InputFormat
+	inputBufferSize int
+}
+
+type Option func(*config)
+
+func newConfig(opt ...Option) *config {
+	c := &config{
+		maxDepth: -1,
+	}
+	for _, f := range opt {
+		f(c)
+	}
+	if c.followSymlinks && c.visited == nil {
+		c.visited = NewVisited()
+	}
+	return c
+}
+
+// WithFollowSymlinks follows symbolic links.
+// Directories and files reached through several links are yielded only once.
+func WithFollowSymlinks(v bool) Option {
+	return func(c *config) {
+		c.followSymlinks = v
+	}
+}
+
+// WithOneFileSystem does not descend directories on other filesystems than the root.
+func WithOneFileSystem(v bool) Option {
+	return func(c *config) {
+		c.oneFileSystem = v
+	}
+}
+
+// WithVisited shares the visited files between walkers to dedup them.
+func WithVisited(v *Visited) Option {
+	return func(c *config) {
+		c.visited = v
+	}
+}
+
+// WithOrdered makes ParallelWalker yield files in the same order as FileWalker.
+func WithOrdered(v bool) Option {
+	return func(c *config) {
+		c.ordered = v
+	}
+}
+
+// WithMaxDepth descends at most n levels of directories below the root.
+// 0 means only the root, negative means no limit.
+func WithMaxDepth(n int) Option {
+	return func(c *config) {
+		c.maxDepth = n
+	}
+}
+
+// WithMinDepth yields no files at levels less than n.
+// The files directly under the root are at level 1.
+func WithMinDepth(n int) Option {
+	return func(c *config) {
+		c.minDepth = n
+	}
+}
+
+// WithSkipDir skips directories that match any of the predicates.
+// The root is never skipped.
+func WithSkipDir(f ...SkipDirFunc) Option {
+	return func(c *config) {
+		c.skipDirs = append(c.skipDirs, f...)
+	}
+}
+
+func (c config) skipDir(path string, entries []fs.DirEntry) bool {
+	for _, f := range c.skipDirs {
+		if f(path, entries) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipDirFunc returns true if the directory should be skipped.
+type SkipDirFunc func(path string, entries []fs.DirEntry) bool
+
+// SkipHidden skips directories whose name starts with a dot.
+func SkipHidden() SkipDirFunc {
+	return func(path string, _ []fs.DirEntry) bool {
+		name := filepath.Base(path)
+		return len(name) > 1 && strings.HasPrefix(name, ".") && name != ".."
+	}
+}
+
+// SkipMarker skips directories containing a file of the name, e.g. .nomedia.
+func SkipMarker(name string) SkipDirFunc {
+	return func(_ string, entries []fs.DirEntry) bool {
+		for _, e := range entries {
+			if e.Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,205 @@
+package walk
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+	"sync"
+
+	"github.com/berquerant/fflist/logx"
+)
+
+var (
+	_ Walker = &ParallelWalker{}
+)
+
+func NewParallel(workerNum int, opt ...Option) *ParallelWalker {
+	if workerNum < 1 {
+		workerNum = 1
+	}
+	return &ParallelWalker{
+		config:    newConfig(opt...),
+		workerNum: workerNum,
+	}
+}
+
+// ParallelWalker walks only files under the root like FileWalker,
+// but reads directories concurrently.
+// The order of files is not deterministic unless WithOrdered.
+type ParallelWalker struct {
+	config    *config
+	workerNum int
+	err       error
+}
+
+func (w ParallelWalker) Err() error { return w.err }
+
+func (w *ParallelWalker) Walk(root string) iter.Seq[Entry] {
+	w.err = nil
+
+	return func(yield func(Entry) bool) {
+		resultC := make(chan Entry, walkerBufferSize)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			defer close(resultC)
+
+			t := newTraverser(ctx, w.config, func(e Entry) {
+				select {
+				case <-ctx.Done():
+				case resultC <- e:
+				}
+			})
+			p := &parallelTraverser{
+				traverser: t,
+				sem:       make(chan struct{}, w.workerNum),
+			}
+			if err := p.run(root); err != nil {
+				w.err = err
+			}
+		}()
+
+		for x := range resultC {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+type parallelTraverser struct {
+	*traverser
+	sem chan struct{}
+}
+
+func (t *parallelTraverser) run(root string) error {
+	n, err := t.root(root)
+	if err != nil || n == nil {
+		return err
+	}
+	if !n.isDir {
+		t.send(NewEntry(n.path, n.info))
+		return nil
+	}
+	if t.ordered {
+		// the order of the visits should be the same as the order of the files
+		t.noVisit = true
+		ctx, cancel := context.WithCancel(t.ctx)
+		defer cancel()
+		return t.walkOrdered(ctx, *n, t.prefetch(ctx, *n))
+	}
+	return t.walkUnordered(*n)
+}
+
+// list reads the directory with the bounded concurrency.
+// Returns nothing if ctx is canceled.
+func (t *parallelTraverser) list(ctx context.Context, n node) ([]node, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil
+	case t.sem <- struct{}{}:
+	}
+	defer func() { <-t.sem }()
+	return t.traverser.list(n)
+}
+
+func (t *parallelTraverser) walkUnordered(root node) error {
+	var (
+		wg          sync.WaitGroup
+		errOnce     sync.Once
+		err         error
+		ctx, cancel = context.WithCancel(t.ctx)
+		visit       func(node)
+	)
+	defer cancel()
+
+	visit = func(n node) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			children, listErr := t.list(ctx, n)
+			if listErr != nil {
+				slog.Debug("ParallelWalker", slog.String("path", n.path), logx.Err(listErr))
+				errOnce.Do(func() {
+					err = listErr
+					cancel()
+				})
+				return
+			}
+			for _, c := range children {
+				if c.isDir {
+					visit(c)
+					continue
+				}
+				t.send(NewEntry(c.path, c.info))
+			}
+		}()
+	}
+
+	visit(root)
+	wg.Wait()
+	return err
+}
+
+// listing is the result of the directory being read in background.
+type listing struct {
+	done     chan struct{}
+	children []node
+	err      error
+}
+
+func (t *parallelTraverser) prefetch(ctx context.Context, n node) *listing {
+	x := &listing{
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(x.done)
+		x.children, x.err = t.list(ctx, n)
+	}()
+	return x
+}
+
+// walkOrdered yields files in the same order as traverser.walk.
+// The subdirectories of the directory being yielded are read in background,
+// and they are canceled by ctx when the walk stops.
+// The visited files and directories are deduped here in order.
+func (t *parallelTraverser) walkOrdered(ctx context.Context, n node, x *listing) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-x.done:
+	}
+	if x.err != nil {
+		return x.err
+	}
+	if (t.maxDepth < 0 || n.depth < t.maxDepth) && !t.visit(n) {
+		// avoid loops, the directories not read are not visited like traverser.list
+		return nil
+	}
+
+	subs := make([]*listing, len(x.children))
+	for i, c := range x.children {
+		if c.isDir {
+			subs[i] = t.prefetch(ctx, c)
+		}
+	}
+	for i, c := range x.children {
+		if !c.isDir {
+			if t.visit(c) {
+				t.send(NewEntry(c.path, c.info))
+			}
+			continue
+		}
+		if err := t.walkOrdered(ctx, c, subs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/metric"
+)
+
+// node is a file or a directory found by the traverser.
+type node struct {
+	path  string
+	info  fs.FileInfo // nil if the directory does not need info
+	depth int
+	isDir bool
+}
+
+// traverser walks the tree by filepath.WalkDir, or by os.ReadDir when following symbolic links.
+type traverser struct {
+	*config
+	ctx      context.Context
+	send     func(Entry)
+	rootInfo fs.FileInfo
+	// noVisit leaves the dedup of the visited files to the caller
+	noVisit bool
+}
+
+func newTraverser(ctx context.Context, c *config, send func(Entry)) *traverser {
+	return &traverser{
+		config: c,
+		ctx:    ctx,
+		send:   send,
+	}
+}
+
+// run walks the tree in order.
+func (t *traverser) run(root string) error {
+	n, err := t.root(root)
+	if err != nil || n == nil {
+		return err
+	}
+	if n.isDir && !t.followSymlinks {
+		return t.walkDir(*n)
+	}
+	// filepath.WalkDir does not follow symbolic links
+	return t.walk(*n)
+}
+
+// walkDir walks the tree by filepath.WalkDir.
+// The info of the file is read only if the file is yielded.
+func (t *traverser) walkDir(root node) error {
+	return filepath.WalkDir(root.path, func(path string, d fs.DirEntry, err error) error {
+		select {
+		case <-t.ctx.Done():
+			return filepath.SkipAll
+		default:
+		}
+		if err != nil {
+			return err
+		}
+
+		n := node{
+			path:  path,
+			isDir: d.IsDir(),
+		}
+		if path == root.path {
+			// counted by root
+			n.info = root.info
+		} else {
+			slog.Debug("FileWalker", slog.String("path", path))
+			metric.IncrEntryCount()
+			n.depth = depthOf(root.path, path)
+		}
+
+		if n.isDir {
+			return t.enterDir(n, d)
+		}
+		if n.depth < t.minDepth {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		t.send(NewEntry(path, info))
+		return nil
+	})
+}
+
+// enterDir returns filepath.SkipDir if the directory should not be walked.
+func (t *traverser) enterDir(n node, d fs.DirEntry) error {
+	if t.maxDepth >= 0 && n.depth >= t.maxDepth {
+		return filepath.SkipDir
+	}
+	if t.oneFileSystem {
+		info := n.info
+		if info == nil {
+			x, err := d.Info()
+			if err != nil {
+				return err
+			}
+			info = x
+		}
+		if !sameDevice(t.rootInfo, info) {
+			slog.Debug("FileWalker skip other filesystem", slog.String("path", n.path))
+			return filepath.SkipDir
+		}
+	}
+	metric.IncrDirCount()
+	if n.depth > 0 && len(t.skipDirs) > 0 {
+		// the predicates need the entries
+		entries, err := os.ReadDir(n.path)
+		if err != nil {
+			return err
+		}
+		if t.skipDir(n.path, entries) {
+			slog.Debug("FileWalker skip dir", slog.String("path", n.path))
+			return filepath.SkipDir
+		}
+	}
+	return nil
+}
+
+// depthOf returns the level of the path below the root.
+func depthOf(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+func (t *traverser) walk(n node) error {
+	if !n.isDir {
+		t.send(NewEntry(n.path, n.info))
+		return nil
+	}
+
+	children, err := t.list(n)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		default:
+		}
+		if err := t.walk(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// root returns the node of the root.
+// Returns nil if the root is a file to be ignored.
+func (t *traverser) root(root string) (*node, error) {
+	stat := os.Lstat
+	if t.followSymlinks {
+		stat = os.Stat
+	}
+	info, err := stat(root)
+	slog.Debug("FileWalker", slog.String("path", root), logx.Err(err))
+	metric.IncrEntryCount()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &node{
+		path:  root,
+		info:  info,
+		isDir: info.IsDir(),
+	}
+	if n.isDir {
+		t.rootInfo = info
+		return n, nil
+	}
+	if !t.acceptFile(*n) {
+		return nil, nil
+	}
+	return n, nil
+}
+
+func (t *traverser) acceptFile(n node) bool {
+	if n.depth < t.minDepth {
+		return false
+	}
+	if !t.noVisit && !t.visit(n) {
+		return false
+	}
+	return true
+}
+
+// visit returns false if the node is visited through another link.
+func (t *traverser) visit(n node) bool {
+	if t.followSymlinks && !t.visited.Visit(n.info) {
+		slog.Debug("FileWalker skip visited", slog.String("path", n.path))
+		return false
+	}
+	return true
+}
+
+// list reads the directory and returns the files to be yielded and the directories to be walked.
+func (t *traverser) list(n node) ([]node, error) {
+	if t.maxDepth >= 0 && n.depth >= t.maxDepth {
+		return nil, nil
+	}
+	if !t.noVisit && !t.visit(n) {
+		// avoid loops
+		return nil, nil
+	}
+	if t.oneFileSystem && !sameDevice(t.rootInfo, n.info) {
+		slog.Debug("FileWalker skip other filesystem", slog.String("path", n.path))
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(n.path)
+	if err != nil {
+		return nil, err
+	}
+	metric.IncrDirCount()
+	if n.depth > 0 && t.skipDir(n.path, entries) {
+		slog.Debug("FileWalker skip dir", slog.String("path", n.path))
+		return nil, nil
+	}
+
+	r := make([]node, 0, len(entries))
+	for _, e := range entries {
+		c := node{
+			path:  filepath.Join(n.path, e.Name()),
+			depth: n.depth + 1,
+		}
+		slog.Debug("FileWalker", slog.String("path", c.path))
+		metric.IncrEntryCount()
+
+		isLink := e.Type()&fs.ModeSymlink != 0 && t.followSymlinks
+		if !e.IsDir() && !isLink && c.depth < t.minDepth {
+			// discarded without info
+			continue
+		}
+
+		switch {
+		case isLink:
+			info, err := os.Stat(c.path)
+			if err != nil {
+				slog.Warn("FileWalker broken symlink", slog.String("path", c.path), logx.Err(err))
+				continue
+			}
+			c.info = info
+		case e.IsDir() && !t.needDirInfo():
+		default:
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			c.info = info
+		}
+
+		c.isDir = e.IsDir() || (c.info != nil && c.info.IsDir())
+		if !c.isDir && !t.acceptFile(c) {
+			continue
+		}
+		r = append(r, c)
+	}
+	return r, nil
+}
+
+func (t *traverser) needDirInfo() bool {
+	return t.followSymlinks || t.oneFileSystem
+}
@@ -0,0 +1,53 @@
+package walk
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// fileID identifies a file by device and inode.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// Visited records visited files by device and inode.
+type Visited struct {
+	mux sync.Mutex
+	ids map[fileID]bool
+}
+
+func NewVisited() *Visited {
+	return &Visited{
+		ids: map[fileID]bool{},
+	}
+}
+
+// Visit marks info as visited and returns true if info is not visited yet.
+// Returns true if info has no device and inode.
+func (v *Visited) Visit(info fs.FileInfo) bool {
+	id, ok := fileIDOf(info)
+	if !ok {
+		return true
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if v.ids[id] {
+		return false
+	}
+	v.ids[id] = true
+	return true
+}
+
+func sameDevice(a, b fs.FileInfo) bool {
+	x, ok := fileIDOf(a)
+	if !ok {
+		return true
+	}
+	y, ok := fileIDOf(b)
+	if !ok {
+		return true
+	}
+	return x.dev == y.dev
+}
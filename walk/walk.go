@@ -1,14 +1,11 @@
 package walk
 
 import (
-	"bufio"
 	"context"
 	"io"
-	"io/fs"
 	"iter"
 	"log/slog"
 	"os"
-	"path/filepath"
 
 	"github.com/berquerant/fflist/logx"
 	"github.com/berquerant/fflist/metric"
@@ -25,11 +22,16 @@ var (
 	_ Walker = &FileWalker{}
 )
 
-func NewFile() *FileWalker { return &FileWalker{} }
+func NewFile(opt ...Option) *FileWalker {
+	return &FileWalker{
+		config: newConfig(opt...),
+	}
+}
 
 // FileWalker walks only files under the root.
 type FileWalker struct {
-	err error
+	config *config
+	err    error
 }
 
 func (w FileWalker) Err() error { return w.err }
@@ -49,26 +51,15 @@ func (w *FileWalker) Walk(root string) iter.Seq[Entry] {
 		go func() {
 			defer close(resultC)
 
-			_ = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
-				slog.Debug("FileWalker", slog.String("path", path), logx.Err(err))
-				metric.IncrEntryCount()
-
+			t := newTraverser(ctx, w.config, func(e Entry) {
 				select {
 				case <-ctx.Done():
-					return filepath.SkipAll
-				default:
-					if err != nil {
-						w.err = err
-						return err
-					}
-					if info.IsDir() {
-						// skip dir
-						return nil
-					}
-					resultC <- NewEntry(path, info)
-					return nil
+				case resultC <- e:
 				}
 			})
+			if err := t.run(root); err != nil {
+				w.err = err
+			}
 		}()
 
 		for x := range resultC {
@@ -83,16 +74,25 @@ var (
 	_ Walker = &ReaderWalker{}
 )
 
+// ReaderWalker reads paths from the reader.
+// Directories are walked by the fileWalker.
+//
+// The input is newline-separated paths by default.
+// See WithInputFormat for other formats.
 type ReaderWalker struct {
 	r          io.Reader
 	fileWalker Walker
+	config     *config
 	err        error
 }
 
-func NewReader(r io.Reader, fileWalker Walker) *ReaderWalker {
+// NewReader returns a new ReaderWalker.
+// The options should be the same as the fileWalker to dedup files when following symlinks.
+func NewReader(r io.Reader, fileWalker Walker, opt ...Option) *ReaderWalker {
 	return &ReaderWalker{
 		r:          r,
 		fileWalker: fileWalker,
+		config:     newConfig(opt...),
 	}
 }
 
@@ -113,13 +113,24 @@ func (w *ReaderWalker) Walk(_ string) iter.Seq[Entry] {
 			case <-ctx.Done():
 				return
 			default:
-				scanner := bufio.NewScanner(w.r)
+				scanner := w.config.newScanner(w.r)
 				for scanner.Scan() {
 					select {
 					case <-ctx.Done():
 						return
 					default:
-						path := scanner.Text()
+						path, data, err := w.config.parseInput(scanner.Bytes())
+						if err != nil {
+							slog.Warn("ReaderWalker", logx.Err(err))
+							continue
+						}
+						send := func(e Entry) {
+							if data != nil {
+								e = NewMetaEntry(e, data)
+							}
+							resultC <- e
+						}
+
 						info, err := os.Stat(path)
 						if os.IsNotExist(err) {
 							slog.Debug("ReaderWalker", slog.String("path", path), logx.Err(err))
@@ -135,14 +146,18 @@ func (w *ReaderWalker) Walk(_ string) iter.Seq[Entry] {
 						}
 						if info.IsDir() {
 							for x := range w.fileWalker.Walk(path) {
-								resultC <- x
+								send(x)
 							}
 							if err := w.fileWalker.Err(); err != nil {
 								slog.Warn("ReaderWalker", slog.String("path", path), logx.Err(err))
 							}
 							continue
 						}
-						resultC <- NewEntry(path, info)
+						if w.config.followSymlinks && !w.config.visited.Visit(info) {
+							slog.Debug("ReaderWalker skip visited", slog.String("path", path))
+							continue
+						}
+						send(NewEntry(path, info))
 					}
 				}
 
@@ -1,13 +1,18 @@
 package walk_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/berquerant/fflist/logx"
 	"github.com/berquerant/fflist/walk"
@@ -185,3 +190,472 @@ func TestWalker(t *testing.T) {
 		}
 	})
 }
+
+func TestWalkerFollowSymlinks(t *testing.T) {
+	d := t.TempDir()
+	join := func(p ...string) string {
+		return filepath.Join(append([]string{d}, p...)...)
+	}
+
+	// d
+	//   flink -> real/f1
+	//   link -> real
+	//   real/
+	//     f1
+	//     loop -> d
+	var (
+		real  = join("real")
+		f1    = join("real", "f1")
+		loop  = join("real", "loop")
+		link  = join("link")
+		flink = join("flink")
+	)
+	if !assert.Nil(t, os.Mkdir(real, 0755)) ||
+		!assert.Nil(t, os.WriteFile(f1, nil, 0644)) ||
+		!assert.Nil(t, os.Symlink(d, loop)) ||
+		!assert.Nil(t, os.Symlink(real, link)) ||
+		!assert.Nil(t, os.Symlink(f1, flink)) {
+		return
+	}
+
+	collect := func(t *testing.T, w walk.Walker, root string) []string {
+		got := []string{}
+		for x := range w.Walk(root) {
+			got = append(got, x.Path())
+		}
+		assert.Nil(t, w.Err())
+		slices.Sort(got)
+		return got
+	}
+
+	t.Run("not follow", func(t *testing.T) {
+		assert.Equal(t, []string{flink, link, f1, loop}, collect(t, walk.NewFile(), d))
+	})
+	t.Run("follow", func(t *testing.T) {
+		w := walk.NewFile(walk.WithFollowSymlinks(true))
+		assert.Equal(t, []string{flink}, collect(t, w, d))
+	})
+	t.Run("follow ordered parallel", func(t *testing.T) {
+		walkAll := func(w walk.Walker) []string {
+			got := []string{}
+			for x := range w.Walk(d) {
+				got = append(got, x.Path())
+			}
+			assert.Nil(t, w.Err())
+			return got
+		}
+		want := walkAll(walk.NewFile(walk.WithFollowSymlinks(true)))
+		for range 20 {
+			w := walk.NewParallel(4, walk.WithFollowSymlinks(true), walk.WithOrdered(true))
+			assert.Equal(t, want, walkAll(w))
+		}
+	})
+	t.Run("follow root link", func(t *testing.T) {
+		w := walk.NewFile(walk.WithFollowSymlinks(true))
+		assert.Equal(t, []string{join("link", "f1")}, collect(t, w, link))
+	})
+	t.Run("reader follow", func(t *testing.T) {
+		opt := []walk.Option{
+			walk.WithFollowSymlinks(true),
+			walk.WithVisited(walk.NewVisited()),
+		}
+		r := bytes.NewBufferString(strings.Join([]string{f1, flink, real}, "\n"))
+		w := walk.NewReader(r, walk.NewFile(opt...), opt...)
+		assert.Equal(t, []string{f1}, collect(t, w, ""))
+	})
+}
+
+func TestWalkerFilter(t *testing.T) {
+	d := t.TempDir()
+	join := func(p ...string) string {
+		return filepath.Join(append([]string{d}, p...)...)
+	}
+
+	// d
+	//   f1
+	//   d1/
+	//     f2
+	//     d2/
+	//       f3
+	//   .hidden/
+	//     f4
+	//   nomedia/
+	//     .nomedia
+	//     f5
+	var (
+		f1      = join("f1")
+		d1      = join("d1")
+		f2      = join("d1", "f2")
+		d2      = join("d1", "d2")
+		f3      = join("d1", "d2", "f3")
+		hidden  = join(".hidden")
+		f4      = join(".hidden", "f4")
+		nomedia = join("nomedia")
+		marker  = join("nomedia", ".nomedia")
+		f5      = join("nomedia", "f5")
+	)
+	for _, x := range []string{d1, d2, hidden, nomedia} {
+		if !assert.Nil(t, os.MkdirAll(x, 0755)) {
+			return
+		}
+	}
+	for _, x := range []string{f1, f2, f3, f4, marker, f5} {
+		if !assert.Nil(t, os.WriteFile(x, nil, 0644)) {
+			return
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opt  []walk.Option
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{f4, f2, f3, f1, marker, f5},
+		},
+		{
+			name: "max depth 0",
+			opt:  []walk.Option{walk.WithMaxDepth(0)},
+			want: []string{},
+		},
+		{
+			name: "max depth 1",
+			opt:  []walk.Option{walk.WithMaxDepth(1)},
+			want: []string{f1},
+		},
+		{
+			name: "max depth 2",
+			opt:  []walk.Option{walk.WithMaxDepth(2)},
+			want: []string{f4, f2, f1, marker, f5},
+		},
+		{
+			name: "min depth 2",
+			opt:  []walk.Option{walk.WithMinDepth(2)},
+			want: []string{f4, f2, f3, marker, f5},
+		},
+		{
+			name: "min depth 2 max depth 2",
+			opt:  []walk.Option{walk.WithMinDepth(2), walk.WithMaxDepth(2)},
+			want: []string{f4, f2, marker, f5},
+		},
+		{
+			name: "skip hidden",
+			opt:  []walk.Option{walk.WithSkipDir(walk.SkipHidden())},
+			want: []string{f2, f3, f1, marker, f5},
+		},
+		{
+			name: "skip marker",
+			opt:  []walk.Option{walk.WithSkipDir(walk.SkipMarker(".nomedia"))},
+			want: []string{f4, f2, f3, f1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			collect := func(w walk.Walker) []string {
+				got := []string{}
+				for x := range w.Walk(d) {
+					got = append(got, x.Path())
+				}
+				assert.Nil(t, w.Err())
+				return got
+			}
+
+			fileGot := collect(walk.NewFile(tc.opt...))
+			// walked by os.ReadDir instead of filepath.WalkDir
+			followGot := collect(walk.NewFile(append(tc.opt, walk.WithFollowSymlinks(true))...))
+			assert.Equal(t, fileGot, followGot, "follow symlinks")
+			orderedGot := collect(walk.NewParallel(4, append(tc.opt, walk.WithOrdered(true))...))
+			assert.Equal(t, fileGot, orderedGot, "ordered parallel")
+
+			unorderedGot := collect(walk.NewParallel(4, tc.opt...))
+			slices.Sort(fileGot)
+			slices.Sort(unorderedGot)
+			slices.Sort(tc.want)
+			assert.Equal(t, tc.want, fileGot)
+			assert.Equal(t, tc.want, unorderedGot, "unordered parallel")
+		})
+	}
+}
+
+func TestReaderWalkerInput(t *testing.T) {
+	d := t.TempDir()
+	var (
+		f1 = filepath.Join(d, "f1")
+		f2 = filepath.Join(d, "new\nline")
+	)
+	for _, x := range []string{f1, f2} {
+		if !assert.Nil(t, os.WriteFile(x, nil, 0644)) {
+			return
+		}
+	}
+
+	type result struct {
+		path string
+		meta map[string]string
+	}
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		opt   []walk.Option
+		want  []result
+	}{
+		{
+			name:  "null",
+			input: f1 + "\x00" + f2 + "\x00",
+			opt:   []walk.Option{walk.WithInputFormat(walk.NullInput)},
+			want: []result{
+				{path: f1},
+				{path: f2},
+			},
+		},
+		{
+			name:  "long line",
+			input: `{"path":"` + f1 + `","comment":"` + strings.Repeat("x", 100*1024) + `"}`,
+			opt:   []walk.Option{walk.WithInputFormat(walk.JSONLInput)},
+			want: []result{
+				{
+					path: f1,
+					meta: map[string]string{
+						"comment": strings.Repeat("x", 100*1024),
+					},
+				},
+			},
+		},
+		{
+			name: "jsonl",
+			input: strings.Join([]string{
+				`{"path":"` + f1 + `","artist":"ARTIST","n":1.50}`,
+				`{"artist":"NOPATH"}`,
+				`invalid`,
+			}, "\n"),
+			opt: []walk.Option{walk.WithInputFormat(walk.JSONLInput)},
+			want: []result{
+				{
+					path: f1,
+					meta: map[string]string{
+						"artist": "ARTIST",
+						"n":      "1.50",
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := walk.NewReader(bytes.NewBufferString(tc.input), walk.NewFile(tc.opt...), tc.opt...)
+			got := []result{}
+			for x := range w.Walk("") {
+				r := result{
+					path: x.Path(),
+				}
+				if m, ok := x.(walk.MetaEntry); ok {
+					r.meta = m.Meta()
+				}
+				got = append(got, r)
+			}
+			assert.Nil(t, w.Err())
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestArchiveWalker(t *testing.T) {
+	d := t.TempDir()
+	var (
+		plain        = filepath.Join(d, "plain.wav")
+		zipFile      = filepath.Join(d, "bundle.zip")
+		tarFile      = filepath.Join(d, "bundle.tar.gz")
+		plainTarFile = filepath.Join(d, "plain.tar")
+		// requires the PAX header
+		longName = strings.Repeat("long/", 30) + "track.wav"
+		members  = map[string]string{
+			"dir/track.wav": "TRACK",
+			"sample.wav":    "SAMPLE",
+			longName:        "LONG",
+		}
+		names = []string{"dir/track.wav", "sample.wav"}
+	)
+
+	if !assert.Nil(t, os.WriteFile(plain, []byte("PLAIN"), 0644)) {
+		return
+	}
+	t.Run("init zip", func(t *testing.T) {
+		f, err := os.Create(zipFile)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer f.Close()
+		w := zip.NewWriter(f)
+		for _, name := range names {
+			x, err := w.Create(name)
+			if !assert.Nil(t, err) {
+				return
+			}
+			_, _ = x.Write([]byte(members[name]))
+		}
+		assert.Nil(t, w.Close())
+	})
+	writeTar := func(t *testing.T, out io.Writer, names []string) {
+		w := tar.NewWriter(out)
+		for _, name := range names {
+			if !assert.Nil(t, w.WriteHeader(&tar.Header{
+				Name:     name,
+				Mode:     0644,
+				Size:     int64(len(members[name])),
+				Typeflag: tar.TypeReg,
+				Uname:    "USER",
+			})) {
+				return
+			}
+			_, _ = w.Write([]byte(members[name]))
+		}
+		assert.Nil(t, w.Close())
+	}
+	t.Run("init tar", func(t *testing.T) {
+		f, err := os.Create(tarFile)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer f.Close()
+		g := gzip.NewWriter(f)
+		writeTar(t, g, names)
+		assert.Nil(t, g.Close())
+	})
+	t.Run("init plain tar", func(t *testing.T) {
+		f, err := os.Create(plainTarFile)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer f.Close()
+		writeTar(t, f, append(names, longName))
+	})
+
+	w := walk.NewArchive(walk.NewFile())
+	got := map[string]string{}
+	for x := range w.Walk(d) {
+		content := func() string {
+			if o, ok := x.(walk.Opener); ok {
+				r, err := o.Open()
+				if !assert.Nil(t, err) {
+					return ""
+				}
+				defer r.Close()
+				b, _ := io.ReadAll(r)
+				return string(b)
+			}
+			b, _ := os.ReadFile(x.Path())
+			return string(b)
+		}()
+		got[x.Path()] = content
+	}
+	assert.Nil(t, w.Err())
+	assert.Equal(t, map[string]string{
+		plain:                            "PLAIN",
+		zipFile + "!/dir/track.wav":      "TRACK",
+		zipFile + "!/sample.wav":         "SAMPLE",
+		tarFile + "!/dir/track.wav":      "TRACK",
+		tarFile + "!/sample.wav":         "SAMPLE",
+		plainTarFile + "!/dir/track.wav": "TRACK",
+		plainTarFile + "!/sample.wav":    "SAMPLE",
+		plainTarFile + "!/" + longName:   "LONG",
+	}, got)
+
+	t.Run("plain tar without rescan", func(t *testing.T) {
+		var entries []walk.Entry
+		for x := range walk.NewArchive(walk.NewFile()).Walk(plainTarFile) {
+			entries = append(entries, x)
+		}
+		if !assert.Equal(t, 3, len(entries)) {
+			return
+		}
+		// break the header of the first member
+		f, err := os.OpenFile(plainTarFile, os.O_WRONLY, 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, err = f.WriteAt(make([]byte, 512), 0)
+		assert.Nil(t, f.Close())
+		if !assert.Nil(t, err) {
+			return
+		}
+		for _, x := range entries[1:] {
+			r, err := x.(walk.Opener).Open()
+			if !assert.Nil(t, err, x.Path()) {
+				continue
+			}
+			b, _ := io.ReadAll(r)
+			assert.Nil(t, r.Close())
+			assert.Equal(t, got[x.Path()], string(b))
+		}
+	})
+}
+
+func TestFSWalker(t *testing.T) {
+	fsys := fstest.MapFS{
+		"f1":               &fstest.MapFile{},
+		"d1/f2":            &fstest.MapFile{},
+		"d1/d2/f3":         &fstest.MapFile{},
+		".hidden/f4":       &fstest.MapFile{},
+		"nomedia/.nomedia": &fstest.MapFile{},
+		"nomedia/f5":       &fstest.MapFile{},
+	}
+
+	for _, tc := range []struct {
+		name string
+		root string
+		opt  []walk.Option
+		want []string
+	}{
+		{
+			name: "all",
+			root: ".",
+			want: []string{".hidden/f4", "d1/d2/f3", "d1/f2", "f1", "nomedia/.nomedia", "nomedia/f5"},
+		},
+		{
+			name: "sub",
+			root: "d1",
+			want: []string{"d1/d2/f3", "d1/f2"},
+		},
+		{
+			name: "file",
+			root: "d1/f2",
+			want: []string{"d1/f2"},
+		},
+		{
+			name: "max depth 1",
+			root: ".",
+			opt:  []walk.Option{walk.WithMaxDepth(1)},
+			want: []string{"f1"},
+		},
+		{
+			name: "min depth 2",
+			root: "d1",
+			opt:  []walk.Option{walk.WithMinDepth(2)},
+			want: []string{"d1/d2/f3"},
+		},
+		{
+			name: "skip",
+			root: ".",
+			opt: []walk.Option{
+				walk.WithSkipDir(walk.SkipHidden(), walk.SkipMarker(".nomedia")),
+			},
+			want: []string{"d1/d2/f3", "d1/f2", "f1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := walk.NewFS(fsys, tc.opt...)
+			got := []string{}
+			for x := range w.Walk(tc.root) {
+				got = append(got, x.Path())
+			}
+			assert.Nil(t, w.Err())
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("not exist", func(t *testing.T) {
+		w := walk.NewFS(fsys)
+		assert.Equal(t, 0, len(slices.Collect(w.Walk("notexist"))))
+		assert.NotNil(t, w.Err())
+	})
+}
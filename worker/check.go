@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/logx"
+	"github.com/berquerant/fflist/meta"
+)
+
+const (
+	checkWorkerBufferSize = 100
+)
+
+// Checker checks each file once, even if the file has multiple metadata, e.g. tracks of CUE sheet.
+// The members of archives are skipped because the decoder cannot read them from the path.
+type Checker struct {
+	checker   meta.Checker
+	workerNum int
+
+	mux     sync.Mutex
+	checked map[string]bool
+}
+
+func NewCheck(checker meta.Checker, workerNum int) *Checker {
+	if workerNum < 1 {
+		workerNum = 1
+	}
+	return &Checker{
+		checker:   checker,
+		workerNum: workerNum,
+		checked:   map[string]bool{},
+	}
+}
+
+func (w *Checker) Start(ctx context.Context, dataC <-chan info.Getter) <-chan *meta.CheckResult {
+	var (
+		wg      sync.WaitGroup
+		resultC = make(chan *meta.CheckResult, checkWorkerBufferSize)
+	)
+
+	for i := range w.workerNum {
+		wg.Add(1)
+		go func() {
+			slog.Debug("Checker Start", slog.Int("n", i))
+			defer wg.Done()
+
+			for data := range dataC {
+				if r, ok := w.check(ctx, data); ok {
+					resultC <- r
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultC)
+		slog.Debug("Checker Stop")
+	}()
+
+	return resultC
+}
+
+func (w *Checker) check(ctx context.Context, data info.Getter) (*meta.CheckResult, bool) {
+	path, isTrack := data.Get("source_path") // a track of CUE sheet
+	if !isTrack {
+		path, _ = data.Get("path")
+	}
+	if _, ok := data.Get("archive"); ok {
+		slog.Warn("Cannot check the member of the archive", slog.String("path", path))
+		return nil, false
+	}
+	if !w.visit(path) {
+		slog.Debug("Checker skip checked", slog.String("path", path))
+		return nil, false
+	}
+	var expected time.Duration
+	if x, ok := data.Get("duration"); ok && !isTrack {
+		// the duration of the track is not of the file
+		expected, _ = meta.ParseSeconds(x)
+	}
+
+	r, err := w.checker.Check(ctx, path, expected)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			slog.Warn("Failed to check", logx.Err(err))
+		}
+		return nil, false
+	}
+	return r, true
+}
+
+// visit returns true if the path is not checked yet.
+func (w *Checker) visit(path string) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.checked[path] {
+		return false
+	}
+	w.checked[path] = true
+	return true
+}
@@ -0,0 +1,93 @@
+package worker_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/fflist/info"
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordChecker records the checked paths and the expected durations.
+type recordChecker struct {
+	mux      sync.Mutex
+	expected map[string][]time.Duration
+}
+
+func (c *recordChecker) Check(_ context.Context, path string, expected time.Duration) (*meta.CheckResult, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.expected[path] = append(c.expected[path], expected)
+	return &meta.CheckResult{
+		Path: path,
+		OK:   true,
+	}, nil
+}
+
+func TestChecker(t *testing.T) {
+	data := func(d map[string]string) info.Getter {
+		return info.New(meta.NewData(d))
+	}
+	for _, tc := range []struct {
+		title string
+		data  []info.Getter
+		want  map[string][]time.Duration
+	}{
+		{
+			title: "files",
+			data: []info.Getter{
+				data(map[string]string{"path": "a.flac", "duration": "1.5"}),
+				data(map[string]string{"path": "b.flac"}),
+			},
+			want: map[string][]time.Duration{
+				"a.flac": {1500 * time.Millisecond},
+				"b.flac": {0},
+			},
+		},
+		{
+			title: "tracks of cue sheet",
+			data: []info.Getter{
+				data(map[string]string{"path": "album.flac", "source_path": "album.flac", "duration": "60"}),
+				data(map[string]string{"path": "album.flac", "source_path": "album.flac", "duration": "30"}),
+				data(map[string]string{"path": "b.flac", "duration": "2"}),
+				data(map[string]string{"path": "b.flac", "duration": "2"}),
+			},
+			want: map[string][]time.Duration{
+				"album.flac": {0},
+				"b.flac":     {2 * time.Second},
+			},
+		},
+		{
+			title: "archive members",
+			data: []info.Getter{
+				data(map[string]string{"path": "a.zip!/a.flac", "archive": "a.zip"}),
+				data(map[string]string{"path": "b.flac"}),
+			},
+			want: map[string][]time.Duration{
+				"b.flac": {0},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			dataC := make(chan info.Getter, len(tc.data))
+			for _, x := range tc.data {
+				dataC <- x
+			}
+			close(dataC)
+
+			checker := &recordChecker{
+				expected: map[string][]time.Duration{},
+			}
+			var got int
+			for range worker.NewCheck(checker, 2).Start(context.TODO(), dataC) {
+				got++
+			}
+			assert.Equal(t, tc.want, checker.expected)
+			assert.Equal(t, len(tc.want), got)
+		})
+	}
+}
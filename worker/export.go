@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/berquerant/fflist/export"
+	"github.com/berquerant/fflist/info"
+)
+
+const (
+	exportWorkerBufferSize = 100
+)
+
+type Exporter struct {
+	exporter  *export.Exporter
+	workerNum int
+}
+
+func NewExport(exporter *export.Exporter, workerNum int) *Exporter {
+	if workerNum < 1 {
+		workerNum = 1
+	}
+	return &Exporter{
+		exporter:  exporter,
+		workerNum: workerNum,
+	}
+}
+
+func (w *Exporter) Start(ctx context.Context, dataC <-chan info.Getter) <-chan *export.Result {
+	var (
+		wg      sync.WaitGroup
+		resultC = make(chan *export.Result, exportWorkerBufferSize)
+	)
+
+	for i := range w.workerNum {
+		wg.Add(1)
+		go func() {
+			slog.Debug("Exporter Start", slog.Int("n", i))
+			defer wg.Done()
+
+			for data := range dataC {
+				if ctx.Err() != nil {
+					// drain
+					continue
+				}
+				resultC <- w.exporter.Export(ctx, data)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultC)
+		slog.Debug("Exporter Stop")
+	}()
+
+	return resultC
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 
 	"github.com/berquerant/fflist/info"
 	"github.com/berquerant/fflist/logx"
 	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/metric"
 	"github.com/berquerant/fflist/walk"
 )
 
@@ -17,20 +19,49 @@ const (
 )
 
 type Prober struct {
-	prober    meta.Prober
-	workerNum int
+	prober         meta.Prober
+	workerNum      int
+	expanders      []info.Expander
+	failureRecords bool
+	failedCount    uint64
 }
 
-func NewProbe(prober meta.Prober, workerNum int) *Prober {
+type ProbeOption func(*Prober)
+
+// WithExpanders expands the probed metadata into multiple metadata.
+// The first expander that expands the metadata wins.
+func WithExpanders(expanders ...info.Expander) ProbeOption {
+	return func(p *Prober) {
+		p.expanders = append(p.expanders, expanders...)
+	}
+}
+
+// WithFailureRecords emits the files failed to probe with probe_error and probe_stderr keys.
+func WithFailureRecords(v bool) ProbeOption {
+	return func(p *Prober) {
+		p.failureRecords = v
+	}
+}
+
+func NewProbe(prober meta.Prober, workerNum int, opt ...ProbeOption) *Prober {
 	if workerNum < 1 {
 		workerNum = 1
 	}
-	return &Prober{
+	p := &Prober{
 		prober:    prober,
 		workerNum: workerNum,
 	}
+	for _, f := range opt {
+		f(p)
+	}
+	return p
 }
 
+func (w *Prober) WorkerNum() int { return w.workerNum }
+
+// FailedCount returns the number of the files failed to probe.
+func (w *Prober) FailedCount() uint64 { return atomic.LoadUint64(&w.failedCount) }
+
 func (w *Prober) Start(ctx context.Context, entryC <-chan walk.Entry) <-chan info.Getter {
 	var (
 		wg      sync.WaitGroup
@@ -44,7 +75,13 @@ func (w *Prober) Start(ctx context.Context, entryC <-chan walk.Entry) <-chan inf
 			defer wg.Done()
 
 			for entry := range entryC {
-				resultC <- w.buildInfoGetter(ctx, entry)
+				data, err := buildMetadata(ctx, w.prober, entry, w.failureRecords)
+				if err != nil {
+					metric.Incr(&w.failedCount)
+				}
+				for _, x := range w.expand(ctx, data) {
+					resultC <- x
+				}
 			}
 		}()
 	}
@@ -58,21 +95,76 @@ func (w *Prober) Start(ctx context.Context, entryC <-chan walk.Entry) <-chan inf
 	return resultC
 }
 
-func (w *Prober) buildInfoGetter(ctx context.Context, entry walk.Entry) info.Getter {
-	return BuildInfoGetter(ctx, w.prober, entry)
+func (w *Prober) expand(ctx context.Context, data *info.Metadata) []*info.Metadata {
+	for _, e := range w.expanders {
+		xs, err := e.Expand(ctx, data)
+		if err != nil {
+			path, _ := data.Get("path")
+			slog.Warn("Failed to expand", slog.String("path", path), logx.Err(err))
+			continue
+		}
+		if len(xs) > 0 {
+			return xs
+		}
+	}
+	return []*info.Metadata{data}
 }
 
 func BuildInfoGetter(ctx context.Context, prober meta.Prober, entry walk.Entry) info.Getter {
+	data, _ := buildMetadata(ctx, prober, entry, false)
+	return data
+}
+
+// buildMetadata returns the metadata of the entry, and the error of the prober except cancellation.
+// The metadata is returned even if the prober fails.
+func buildMetadata(ctx context.Context, prober meta.Prober, entry walk.Entry, failureRecords bool) (*info.Metadata, error) {
 	r := []*meta.Data{
 		info.NewMetadataFromEntry(entry),
 	}
 
-	data, err := prober.Probe(ctx, entry.Path())
-	if err != nil && !errors.Is(err, context.Canceled) {
-		slog.Warn("Failed to probe", logx.Err(err))
-	} else {
+	data, err := probe(ctx, prober, entry)
+	var probeErr error
+	switch {
+	case err == nil:
+		if _, ok := prober.(meta.UnderlayProber); ok {
+			r = append([]*meta.Data{data}, r...)
+			break
+		}
 		r = append(r, data)
+	case errors.Is(err, context.Canceled):
+	default:
+		probeErr = err
+		slog.Warn("Failed to probe", logx.Err(err))
+		if failureRecords {
+			r = append(r, meta.NewData(map[string]string{
+				"probe_error":  err.Error(),
+				"probe_stderr": meta.Stderr(err),
+			}))
+		}
 	}
 
-	return info.New(r...)
+	if e, ok := entry.(walk.MetaEntry); ok {
+		// pre-known metadata wins
+		r = append(r, meta.NewData(e.Meta()))
+	}
+
+	return info.New(r...), probeErr
+}
+
+func probe(ctx context.Context, prober meta.Prober, entry walk.Entry) (*meta.Data, error) {
+	opener, ok := entry.(walk.Opener)
+	if !ok {
+		return prober.Probe(ctx, entry.Path())
+	}
+	readerProber, ok := prober.(meta.ReaderProber)
+	if !ok {
+		return prober.Probe(ctx, entry.Path())
+	}
+
+	r, err := opener.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readerProber.ProbeReader(ctx, entry.Path(), r)
 }
@@ -0,0 +1,77 @@
+package worker_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/fflist/meta"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeFailureRecords(t *testing.T) {
+	d := t.TempDir()
+	var (
+		probe = filepath.Join(d, "ffprobe")
+		path  = filepath.Join(d, "a.mp3")
+	)
+	if err := os.WriteFile(probe, []byte(`#!/bin/sh
+echo "Invalid data found" >&2
+exit 1
+`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		title  string
+		opt    []worker.ProbeOption
+		record bool
+	}{
+		{
+			title: "without failure records",
+		},
+		{
+			title:  "failure records",
+			opt:    []worker.ProbeOption{worker.WithFailureRecords(true)},
+			record: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			entryC := make(chan walk.Entry, 1)
+			entryC <- walk.NewEntry(path, stat)
+			close(entryC)
+
+			var got []map[string]string
+			for x := range worker.NewProbe(meta.NewProber(probe), 1, tc.opt...).Start(context.TODO(), entryC) {
+				r := map[string]string{}
+				for _, k := range []string{"path", "size", "probe_error", "probe_stderr"} {
+					if v, ok := x.Get(k); ok {
+						r[k] = v
+					}
+				}
+				got = append(got, r)
+			}
+			if !assert.Equal(t, 1, len(got)) {
+				return
+			}
+			assert.Equal(t, path, got[0]["path"])
+			assert.Equal(t, "1", got[0]["size"])
+			if !tc.record {
+				assert.NotContains(t, got[0], "probe_error")
+				return
+			}
+			assert.Contains(t, got[0]["probe_error"], path)
+			assert.Equal(t, "Invalid data found\n", got[0]["probe_stderr"])
+		})
+	}
+}
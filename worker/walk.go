@@ -3,7 +3,9 @@ package worker
 import (
 	"context"
 	"log/slog"
+	"time"
 
+	"github.com/berquerant/fflist/metric"
 	"github.com/berquerant/fflist/walk"
 	"golang.org/x/sync/errgroup"
 )
@@ -29,20 +31,26 @@ func (w *Walker) Start(ctx context.Context, root ...string) <-chan walk.Entry {
 	w.err = nil
 	eg, ctx := errgroup.WithContext(ctx)
 	entryC := make(chan walk.Entry, walkWorkerBufferSize)
+	// time spent in walking per root, excluding waiting for the receivers
+	durations := make([]time.Duration, len(root))
 
 	for i, r := range root {
 		eg.Go(func() error {
 			slog.Debug("Walker Start", slog.Int("n", i), slog.String("root", r))
 
 			walker := w.newWalker()
+			startTime := time.Now()
 			for entry := range walker.Walk(r) {
+				durations[i] += time.Since(startTime)
 				select {
 				case <-ctx.Done():
 					break
 				default:
 					entryC <- entry
 				}
+				startTime = time.Now()
 			}
+			durations[i] += time.Since(startTime)
 			return walker.Err()
 		})
 	}
@@ -51,6 +59,12 @@ func (w *Walker) Start(ctx context.Context, root ...string) <-chan walk.Entry {
 		if err := eg.Wait(); err != nil {
 			w.err = err
 		}
+		// the roots are walked concurrently
+		var d time.Duration
+		for _, x := range durations {
+			d = max(d, x)
+		}
+		metric.SetWalkDuration(d)
 		close(entryC)
 		slog.Debug("Walker Stop")
 	}()
@@ -0,0 +1,37 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/berquerant/fflist/metric"
+	"github.com/berquerant/fflist/walk"
+	"github.com/berquerant/fflist/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkerDurationExcludesReceivers(t *testing.T) {
+	const (
+		// more than the buffer of the walker
+		n     = 150
+		delay = 2 * time.Millisecond
+	)
+	fsys := fstest.MapFS{}
+	for i := range n {
+		fsys[fmt.Sprintf("%d.mp3", i)] = &fstest.MapFile{}
+	}
+	w := worker.NewWalker(func() walk.Walker { return walk.NewFS(fsys) })
+
+	var count int
+	for range w.Start(context.TODO(), ".") {
+		count++
+		// slow probes
+		time.Sleep(delay)
+	}
+	assert.Nil(t, w.Err())
+	assert.Equal(t, n, count)
+	assert.Less(t, metric.Get().WalkDuration, (n*delay).Seconds()/4)
+}